@@ -3,79 +3,278 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"spy-cat-agency/config"
+	"spy-cat-agency/internal/apikey"
+	"spy-cat-agency/internal/audit"
+	"spy-cat-agency/internal/auth"
 	"spy-cat-agency/internal/cat"
+	"spy-cat-agency/internal/change"
 	"spy-cat-agency/internal/db"
+	"spy-cat-agency/internal/health"
+	"spy-cat-agency/internal/idempotency"
+	"spy-cat-agency/internal/logging"
+	"spy-cat-agency/internal/metrics"
 	"spy-cat-agency/internal/middleware"
 	"spy-cat-agency/internal/mission"
+	"spy-cat-agency/internal/outbox"
+	"spy-cat-agency/internal/socket"
+	"spy-cat-agency/internal/stream"
+	"spy-cat-agency/internal/tracing"
+	"spy-cat-agency/internal/webhook"
+	"sync"
 	"syscall"
 	"time"
 )
 
 func main() {
-	if err := run(); err != nil {
-		log.Fatal(err)
+	if err := run(os.Args[1:]); err != nil && !errors.Is(err, config.ErrHelp) {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
 	}
 }
 
-func run() error {
+func run(args []string) error {
 	gin.SetMode(gin.ReleaseMode)
 
-	c, err := config.New()
+	c, err := config.New(args)
 	if err != nil {
 		return err
 	}
 
-	conn, err := db.Connect(c.Database.User, c.Database.Password,
-		c.Database.Host, c.Database.DBName, c.Database.Port)
+	logger, err := logging.New(c.Log.Level)
 	if err != nil {
 		return err
 	}
+	slog.SetDefault(logger)
 
-	err = db.Migrate(conn, "./migrations")
+	shutdownTracing, err := tracing.Setup(context.Background(), c.Tracing)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
+	conn, err := db.Connect(context.Background(), c.Database)
+	if err != nil {
+		return err
+	}
+
+	latestMigration, err := db.LatestMigration(c.Database.MigrationsPath)
+	if err != nil {
+		return err
+	}
+
+	if c.Database.AutoMigrate {
+		err = db.Migrate(conn.DB, c.Database.MigrationsPath)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = db.CheckSchema(context.Background(), conn, latestMigration)
+		if err != nil {
+			return fmt.Errorf("refusing to start: %w", err)
+		}
+	}
+
+	var workers sync.WaitGroup
+	ctx, stop := context.WithCancel(context.Background())
+	defer func() {
+		stop()
+		workers.Wait()
+	}()
+
+	idempotencyStore := idempotency.NewStore(conn, c.Idempotency.TTL, c.Idempotency.Lease)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		idempotencyStore.RunCleanup(ctx, c.Idempotency.CleanupInterval)
+	}()
+	idempotent := middleware.Idempotency(idempotencyStore)
+
+	wr := webhook.NewRepository(conn)
+	ws := webhook.NewService(wr)
+	wh := webhook.NewHandler(ws)
+
+	broker := stream.NewBroker(c.Stream)
+	sh := stream.NewHandler(broker)
+
+	outboxRepository := outbox.NewRepository(conn)
+	events := outbox.NewService(outboxRepository)
+	dispatcher := outbox.NewDispatcher(outboxRepository, outbox.Publishers{ws, stream.NewNotifier(conn)}, c.Outbox)
+	deliverer := webhook.NewDeliverer(wr, c.Webhooks)
+	workers.Add(3)
+	go func() {
+		defer workers.Done()
+		dispatcher.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		deliverer.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		broker.Listen(ctx, db.NewListener(c.Database))
+	}()
 
 	router := gin.New()
 
-	router.Use(middleware.Logger())
+	requestLogger, err := middleware.NewRequestLogger(c.Log)
+	if err != nil {
+		return err
+	}
+
+	rateLimiter := middleware.NewRateLimiter(c.RateLimit)
+
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Tracing())
+	router.Use(middleware.Metrics())
+	router.Use(requestLogger.Handler())
+	router.Use(middleware.Recovery(c.Server.CrashDumpDir))
+	router.Use(middleware.Problems())
+	router.Use(middleware.Consistency())
+	router.NoRoute(middleware.NoRoute())
+
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	catalog := cat.NewBreedCatalog(c.BreedCatalog.URL, c.BreedCatalog.Timeout, c.BreedCatalog.CacheTTL)
+
+	c.Watch(func(next *config.Config) error {
+		if err := requestLogger.Update(next.Log); err != nil {
+			return err
+		}
+		if err := logging.SetLevel(next.Log.Level); err != nil {
+			return err
+		}
+		catalog.SetTTL(next.BreedCatalog.CacheTTL)
+		rateLimiter.Update(next.RateLimit)
+		return nil
+	})
+
+	hh := health.NewHandler(c.Server.ReadinessTimeout)
+	hh.AddCheck("database", true, conn.PingContext)
+	hh.AddCheck("migrations", true, func(ctx context.Context) error {
+		return db.CheckSchema(ctx, conn, latestMigration)
+	})
+	hh.AddCheck("database_replicas", false, conn.ReplicasHealthy)
+	hh.AddCheck("breed_catalog", false, catalog.Ping)
+
+	router.GET("/healthz", hh.Liveness)
+	router.GET("/readyz", hh.Readiness)
+
+	ar := audit.NewRepository(conn)
+	as := audit.NewService(ar)
+	ah := audit.NewHandler(as)
+
+	changeService := change.NewService(change.NewRepository(conn), c.Changes)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		changeService.RunCleanup(ctx)
+	}()
+	changeHandler := change.NewHandler(changeService)
 
 	cr := cat.NewRepository(conn)
-	cs := cat.NewService(cr)
+	cs := cat.NewService(cr, catalog, as, events)
 	ch := cat.NewHandler(cs)
 
+	validator, err := middleware.Validator(c.Server.OpenAPIPath)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := auth.NewVerifier(c.Auth)
+	if err != nil {
+		return err
+	}
+
+	kr := apikey.NewRepository(conn)
+	ks := apikey.NewService(kr)
+	kh := apikey.NewHandler(ks)
+
 	v1 := router.Group("/api/v1")
+	v1.Use(rateLimiter.InFlight())
+	v1.Use(rateLimiter.Authentication())
+	v1.Use(middleware.Authenticate(verifier, ks))
+	v1.Use(rateLimiter.Handler())
+	v1.Use(validator)
 
 	catRoutes := v1.Group("/cats")
 	{
-		catRoutes.GET("", ch.ListCats)         // api/v1/cats
-		catRoutes.POST("", ch.CreateCat)       // api/v1/cats
-		catRoutes.GET("/:id", ch.GetCat)       // api/v1/cats/:id
-		catRoutes.PATCH("/:id", ch.UpdateCat)  // api/v1/cats/:id
-		catRoutes.DELETE("/:id", ch.DeleteCat) // api/v1/cats/:id
+		catRoutes.GET("", middleware.Require(auth.CatsRead), ch.ListCats)                // api/v1/cats
+		catRoutes.POST("", middleware.Require(auth.CatsWrite), idempotent, ch.CreateCat) // api/v1/cats
+		catRoutes.GET("/:id", middleware.Require(auth.CatsRead), ch.GetCat)              // api/v1/cats/:id
+		catRoutes.PATCH("/:id", middleware.Require(auth.CatsWrite), ch.UpdateCat)        // api/v1/cats/:id
+		catRoutes.DELETE("/:id", middleware.Require(auth.CatsWrite), ch.DeleteCat)       // api/v1/cats/:id
 	}
 
 	mr := mission.NewRepository(conn)
-	ms := mission.NewService(mr, cs)
+
+	metrics.RegisterDB(conn.DB, c.Database.DBName)
+	metrics.RegisterBusinessGauges(mr.CountActiveMissions, mr.CountIdleCats)
+
+	ms := mission.NewService(mr, cs, as, events)
 	mh := mission.NewHandler(ms)
 
 	missionRoutes := v1.Group("/missions")
 	{
-		missionRoutes.GET("", mh.ListMissions)         // api/v1/missions
-		missionRoutes.POST("", mh.CreateMission)       // api/v1/missions
-		missionRoutes.GET("/:id", mh.GetMission)       // api/v1/missions/:id
-		missionRoutes.PATCH("/:id", mh.UpdateMission)  // api/v1/missions/:id
-		missionRoutes.DELETE("/:id", mh.DeleteMission) // api/v1/missions/:id
+		missionRoutes.GET("", middleware.Require(auth.MissionsRead), mh.ListMissions)                // api/v1/missions
+		missionRoutes.POST("", middleware.Require(auth.MissionsWrite), idempotent, mh.CreateMission) // api/v1/missions
+		missionRoutes.GET("/:id", middleware.Require(auth.MissionsRead), mh.GetMission)              // api/v1/missions/:id
+		missionRoutes.PATCH("/:id", middleware.Require(auth.MissionsWrite), mh.UpdateMission)        // api/v1/missions/:id
+		missionRoutes.DELETE("/:id", middleware.Require(auth.MissionsWrite), mh.DeleteMission)       // api/v1/missions/:id
+
+		missionRoutes.POST("/:id/targets", middleware.Require(auth.MissionsWrite), idempotent, mh.AddTarget)     // api/v1/missions/:id/targets
+		missionRoutes.PATCH("/:id/targets/:target_id", middleware.Require(auth.TargetsUpdate), mh.UpdateTarget)  // api/v1/missions/:id/targets/:target_id
+		missionRoutes.DELETE("/:id/targets/:target_id", middleware.Require(auth.MissionsWrite), mh.DeleteTarget) // api/v1/missions/:id/targets/:target_id
+	}
+
+	apiKeyRoutes := v1.Group("/api-keys", middleware.Require(auth.APIKeysManage))
+	{
+		apiKeyRoutes.GET("", kh.ListAPIKeys)         // api/v1/api-keys
+		apiKeyRoutes.POST("", kh.CreateAPIKey)       // api/v1/api-keys
+		apiKeyRoutes.DELETE("/:id", kh.RevokeAPIKey) // api/v1/api-keys/:id
+	}
 
-		missionRoutes.POST("/:id/targets", mh.AddTarget)                 // api/v1/missions/:id/targets
-		missionRoutes.PATCH("/:id/targets/:target_id", mh.UpdateTarget)  // api/v1/missions/:id/targets/:target_id
-		missionRoutes.DELETE("/:id/targets/:target_id", mh.DeleteTarget) // api/v1/missions/:id/targets/:target_id
+	v1.GET("/audit", middleware.Require(auth.AuditRead), ah.ListEvents) // api/v1/audit
+
+	v1.GET("/changes", middleware.Require(auth.CatsRead), middleware.Require(auth.MissionsRead), changeHandler.ListChanges) // api/v1/changes
+
+	webhookRoutes := v1.Group("/webhooks", middleware.Require(auth.WebhooksManage))
+	{
+		webhookRoutes.GET("", wh.ListWebhooks)         // api/v1/webhooks
+		webhookRoutes.POST("", wh.CreateWebhook)       // api/v1/webhooks
+		webhookRoutes.GET("/:id", wh.GetWebhook)       // api/v1/webhooks/:id
+		webhookRoutes.PATCH("/:id", wh.UpdateWebhook)  // api/v1/webhooks/:id
+		webhookRoutes.DELETE("/:id", wh.DeleteWebhook) // api/v1/webhooks/:id
+
+		webhookRoutes.GET("/:id/deliveries", wh.ListDeliveries)                    // api/v1/webhooks/:id/deliveries
+		webhookRoutes.POST("/:id/deliveries/:delivery_id/redeliver", wh.Redeliver) // api/v1/webhooks/:id/deliveries/:delivery_id/redeliver
+	}
+
+	sockets := socket.NewHandler(ms, broker, ks, rateLimiter, c.WebSocket)
+
+	// Streams and sockets stay open, so they are not counted towards the in-flight limit.
+	realtimeRoutes := router.Group("/api/v1")
+	realtimeRoutes.Use(rateLimiter.Authentication())
+	realtimeRoutes.Use(middleware.Authenticate(verifier, ks))
+	realtimeRoutes.Use(rateLimiter.Handler())
+	realtimeRoutes.Use(rateLimiter.Connections())
+	realtimeRoutes.Use(validator)
+	{
+		realtimeRoutes.GET("/events/stream", middleware.Require(auth.MissionsRead), sh.Stream) // api/v1/events/stream
+		realtimeRoutes.GET("/ws", middleware.Require(auth.MissionsRead), sockets.Connect)      // api/v1/ws
 	}
 
 	s := &http.Server{
@@ -85,6 +284,8 @@ func run() error {
 		WriteTimeout: c.Server.WriteTimeout,
 		IdleTimeout:  c.Server.IdleTimeout,
 	}
+	s.RegisterOnShutdown(broker.Close)
+	s.RegisterOnShutdown(sockets.Close)
 
 	done := make(chan bool)
 
@@ -94,26 +295,28 @@ func run() error {
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		<-quit
 
-		log.Println("Shutting down server...")
+		hh.SetShuttingDown()
+		slog.Info("Shutting down server...", "delay", c.Server.ShutdownDelay)
+		time.Sleep(c.Server.ShutdownDelay)
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
 		if err := s.Shutdown(ctx); err != nil {
-			log.Println("Server forced to shutdown:", err)
+			slog.Error("Server forced to shutdown", "error", err)
 		}
 
 		done <- true
 	}()
 
-	log.Println("Starting server on :8080")
+	slog.Info("Starting server", "addr", s.Addr)
 
 	if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
 	<-done
-	log.Println("Graceful shutdown complete")
+	slog.Info("Graceful shutdown complete")
 
 	return nil
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/spf13/pflag"
+	"log/slog"
+	"os"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/db"
+	"strconv"
+)
+
+const usage = `Usage: migrate [flags] <command> [argument]
+
+Commands:
+  up          apply all pending migrations
+  down N      roll back the last N migrations
+  goto V      migrate up or down to version V
+  force V     mark version V as applied and clear the dirty flag, without running migrations
+  status      print the current and latest schema versions
+
+Flags:
+`
+
+func main() {
+	fs := pflag.NewFlagSet("migrate", pflag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	if err := run(fs, os.Args[1:]); err != nil && !errors.Is(err, config.ErrHelp) {
+		slog.Error("Migration failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(fs *pflag.FlagSet, args []string) error {
+	c, err := config.Parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+
+	conn, err := db.Connect(context.Background(), c.Database)
+	if err != nil {
+		return err
+	}
+
+	m, err := db.NewMigrator(conn.DB, c.Database.MigrationsPath)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	command, arg := args[0], args[1:]
+
+	switch command {
+	case "up":
+		err = m.Up()
+	case "down":
+		var n int
+		n, err = intArg(arg, "number of migrations")
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return errors.New("number of migrations must be positive")
+		}
+		err = m.Steps(-n)
+	case "goto":
+		var v int
+		v, err = intArg(arg, "version")
+		if err != nil {
+			return err
+		}
+		err = m.Migrate(uint(v))
+	case "force":
+		var v int
+		v, err = intArg(arg, "version")
+		if err != nil {
+			return err
+		}
+		err = m.Force(v)
+	case "status":
+		return status(m, c.Database.MigrationsPath)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		slog.Info("No change")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return status(m, c.Database.MigrationsPath)
+}
+
+func status(m *migrate.Migrate, path string) error {
+	latest, err := db.LatestMigration(path)
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		version, dirty = 0, false
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Printf("version: %d\ndirty:   %t\nlatest:  %d\n", version, dirty, latest)
+
+	return nil
+}
+
+func intArg(args []string, name string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected exactly one argument: %s", name)
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, args[0], err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/pflag"
+	"log/slog"
+	"os"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/auth"
+	"time"
+)
+
+const usage = `Usage: token --role ROLE [--cat-id ID] [--subject SUBJECT] [--ttl DURATION] [flags]
+
+Signs a JWT with the configured auth key and prints it to stdout. Roles: admin, handler, field_agent.
+
+Flags:
+`
+
+func main() {
+	fs := pflag.NewFlagSet("token", pflag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	if err := run(fs, os.Args[1:]); err != nil && !errors.Is(err, config.ErrHelp) {
+		slog.Error("Signing failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(fs *pflag.FlagSet, args []string) error {
+	role := fs.String("role", "", "role claim: admin, handler or field_agent")
+	catID := fs.Int("cat-id", 0, "cat_id claim, required for field_agent")
+	subject := fs.String("subject", "", "subject claim (defaults to the role, or cat:<id> for field agents)")
+	ttl := fs.Duration("ttl", 24*time.Hour, "token lifetime")
+
+	c, err := config.Load(fs, args)
+	if err != nil {
+		return err
+	}
+
+	signer, err := auth.NewSigner(c.Auth)
+	if err != nil {
+		return err
+	}
+
+	p := &auth.Principal{Subject: *subject, Role: auth.Role(*role)}
+	if fs.Changed("cat-id") {
+		p.CatID = catID
+	}
+	if p.Subject == "" {
+		p.Subject = *role
+		if p.CatID != nil {
+			p.Subject = fmt.Sprintf("cat:%d", *p.CatID)
+		}
+	}
+
+	token, err := signer.Sign(p, *ttl)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(token)
+
+	return nil
+}
@@ -1,43 +1,304 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"os"
+	"sort"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
+	Server       ServerConfig       `mapstructure:"server"`
+	Database     DatabaseConfig     `mapstructure:"database"`
+	Log          LogConfig          `mapstructure:"log"`
+	Tracing      TracingConfig      `mapstructure:"tracing"`
+	BreedCatalog BreedCatalogConfig `mapstructure:"breed_catalog"`
+	Auth         AuthConfig         `mapstructure:"auth"`
+	RateLimit    RateLimitConfig    `mapstructure:"rate_limit"`
+	Idempotency  IdempotencyConfig  `mapstructure:"idempotency"`
+	Outbox       OutboxConfig       `mapstructure:"outbox"`
+	Webhooks     WebhooksConfig     `mapstructure:"webhooks"`
+	Stream       StreamConfig       `mapstructure:"stream"`
+	WebSocket    WebSocketConfig    `mapstructure:"websocket"`
+	Changes      ChangesConfig      `mapstructure:"changes"`
+
+	v *viper.Viper
 }
 
 type ServerConfig struct {
-	Port         string        `mapstructure:"port"`
-	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
-	WriteTimeout time.Duration `mapstructure:"write_timeout"`
-	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+	Port             string        `mapstructure:"port"`
+	ReadTimeout      time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout     time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout      time.Duration `mapstructure:"idle_timeout"`
+	OpenAPIPath      string        `mapstructure:"openapi_path"`
+	CrashDumpDir     string        `mapstructure:"crash_dump_dir"`
+	ReadinessTimeout time.Duration `mapstructure:"readiness_timeout"`
+	ShutdownDelay    time.Duration `mapstructure:"shutdown_delay"`
 }
 
 type DatabaseConfig struct {
-	Host           string `mapstructure:"host"`
-	Port           int    `mapstructure:"port"`
-	User           string `mapstructure:"user"`
-	Password       string `mapstructure:"password"`
-	DBName         string `mapstructure:"dbname"`
-	MigrationsPath string `mapstructure:"migrations_path"`
-}
-
-func New() (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	if err := viper.ReadInConfig(); err != nil {
+	Host             string        `mapstructure:"host"`
+	Port             int           `mapstructure:"port"`
+	User             string        `mapstructure:"user"`
+	Password         string        `mapstructure:"password"`
+	DBName           string        `mapstructure:"dbname"`
+	SSLMode          string        `mapstructure:"sslmode"`
+	SSLRootCert      string        `mapstructure:"sslrootcert"`
+	ApplicationName  string        `mapstructure:"application_name"`
+	StatementTimeout time.Duration `mapstructure:"statement_timeout"`
+	MaxOpenConns     int           `mapstructure:"max_open_conns"`
+	MaxIdleConns     int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime  time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime  time.Duration `mapstructure:"conn_max_idle_time"`
+	ConnectTimeout   time.Duration `mapstructure:"connect_timeout"`
+	MigrationsPath   string        `mapstructure:"migrations_path"`
+	AutoMigrate      bool          `mapstructure:"auto_migrate"`
+
+	Replicas             []string      `mapstructure:"replicas"`
+	ReplicaCheckInterval time.Duration `mapstructure:"replica_check_interval"`
+	ReplicaMaxLag        time.Duration `mapstructure:"replica_max_lag"`
+}
+
+type LogConfig struct {
+	Level        string   `mapstructure:"level"`
+	MaxBodyBytes int      `mapstructure:"max_body_bytes"`
+	SampleRate   float64  `mapstructure:"sample_rate"`
+	Redact       []string `mapstructure:"redact"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	FilePath    string  `mapstructure:"file_path"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+	ServiceName string  `mapstructure:"service_name"`
+}
+
+type BreedCatalogConfig struct {
+	URL      string        `mapstructure:"url"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+}
+
+type AuthConfig struct {
+	Algorithm      string        `mapstructure:"algorithm"`
+	Secret         string        `mapstructure:"secret"`
+	PublicKeyPath  string        `mapstructure:"public_key_path"`
+	PrivateKeyPath string        `mapstructure:"private_key_path"`
+	Issuer         string        `mapstructure:"issuer"`
+	Audience       string        `mapstructure:"audience"`
+	Leeway         time.Duration `mapstructure:"leeway"`
+}
+
+type RateLimitConfig struct {
+	Enabled     bool                 `mapstructure:"enabled"`
+	Rate        float64              `mapstructure:"rate"`
+	Burst       int                  `mapstructure:"burst"`
+	Routes      map[string]RouteRate `mapstructure:"routes"`
+	MaxInFlight int                  `mapstructure:"max_in_flight"`
+	RetryAfter  time.Duration        `mapstructure:"retry_after"`
+
+	AuthFailureRate  float64 `mapstructure:"auth_failure_rate"`
+	AuthFailureBurst int     `mapstructure:"auth_failure_burst"`
+
+	MaxConnectionsPerClient int `mapstructure:"max_connections_per_client"`
+}
+
+type RouteRate struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+type IdempotencyConfig struct {
+	TTL             time.Duration `mapstructure:"ttl"`
+	Lease           time.Duration `mapstructure:"lease"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+}
+
+type OutboxConfig struct {
+	PollInterval    time.Duration `mapstructure:"poll_interval"`
+	BatchSize       int           `mapstructure:"batch_size"`
+	RetryBackoff    time.Duration `mapstructure:"retry_backoff"`
+	MaxRetryBackoff time.Duration `mapstructure:"max_retry_backoff"`
+	MaxAttempts     int           `mapstructure:"max_attempts"`
+	Retention       time.Duration `mapstructure:"retention"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+}
+
+type WebhooksConfig struct {
+	Timeout         time.Duration `mapstructure:"timeout"`
+	PollInterval    time.Duration `mapstructure:"poll_interval"`
+	BatchSize       int           `mapstructure:"batch_size"`
+	MaxAttempts     int           `mapstructure:"max_attempts"`
+	RetryBackoff    time.Duration `mapstructure:"retry_backoff"`
+	MaxRetryBackoff time.Duration `mapstructure:"max_retry_backoff"`
+}
+
+type StreamConfig struct {
+	MaxClients        int           `mapstructure:"max_clients"`
+	BufferSize        int           `mapstructure:"buffer_size"`
+	ClientBuffer      int           `mapstructure:"client_buffer"`
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+}
+
+type WebSocketConfig struct {
+	MaxUnacked        int           `mapstructure:"max_unacked"`
+	MaxMessageBytes   int           `mapstructure:"max_message_bytes"`
+	PingInterval      time.Duration `mapstructure:"ping_interval"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	AuthCheckInterval time.Duration `mapstructure:"auth_check_interval"`
+}
+
+type ChangesConfig struct {
+	Retention       time.Duration `mapstructure:"retention"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+}
+
+const envPrefix = "SPYCAT"
+
+var ErrHelp = pflag.ErrHelp
+
+func New(args []string) (*Config, error) {
+	return Parse(pflag.NewFlagSet("spy-cat-agency", pflag.ContinueOnError), args)
+}
+
+func Parse(fs *pflag.FlagSet, args []string) (*Config, error) {
+	config, err := Load(fs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func Load(fs *pflag.FlagSet, args []string) (*Config, error) {
+	v := viper.New()
+
+	configPath := fs.String("config", "", "path to the configuration file")
+
+	keys := make([]string, 0, len(defaults))
+	for key := range defaults {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v.SetDefault(key, defaults[key])
+		if !addFlag(fs, key, defaults[key]) {
+			continue
+		}
+		if err := v.BindPFlag(key, fs.Lookup(flagName(key))); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
 
-	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	for _, key := range keys {
+		if fs.Changed(flagName(key)) {
+			continue
+		}
+		if err := readSecretFile(v, key); err != nil {
+			return nil, err
+		}
+	}
+
+	if *configPath == "" {
+		*configPath = os.Getenv(envPrefix + "_CONFIG")
+	}
+
+	if *configPath != "" {
+		v.SetConfigFile(*configPath)
+	} else {
+		v.SetConfigName("config")
+		v.SetConfigType("yaml")
+		v.AddConfigPath(".")
+		v.AddConfigPath("./config")
+		v.AddConfigPath("/etc/spy-cat-agency")
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if *configPath != "" || !errors.As(err, &notFound) {
+			return nil, err
+		}
+	}
+
+	return decode(v)
+}
+
+func decode(v *viper.Viper) (*Config, error) {
+	config := Config{v: v}
+	if err := v.Unmarshal(&config); err != nil {
 		return nil, err
 	}
 
 	return &config, nil
 }
+
+func readSecretFile(v *viper.Viper, key string) error {
+	env := envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+
+	path, ok := os.LookupEnv(env + "_FILE")
+	if !ok {
+		return nil
+	}
+
+	if _, ok := os.LookupEnv(env); ok {
+		return fmt.Errorf("both %s and %s_FILE are set", env, env)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%s_FILE: %w", env, err)
+	}
+
+	v.Set(key, strings.TrimRight(string(content), "\r\n"))
+
+	return nil
+}
+
+func flagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+func addFlag(fs *pflag.FlagSet, key string, value any) bool {
+	name := flagName(key)
+	usage := "overrides " + key
+
+	switch d := value.(type) {
+	case string:
+		fs.String(name, d, usage)
+	case int:
+		fs.Int(name, d, usage)
+	case bool:
+		fs.Bool(name, d, usage)
+	case float64:
+		fs.Float64(name, d, usage)
+	case time.Duration:
+		fs.Duration(name, d, usage)
+	case []string:
+		fs.StringSlice(name, d, usage)
+	case map[string]any:
+		return false
+	default:
+		panic(fmt.Sprintf("config: unsupported default type %T for %s", value, key))
+	}
+
+	return true
+}
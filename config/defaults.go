@@ -0,0 +1,116 @@
+package config
+
+import (
+	"time"
+)
+
+var defaults = map[string]any{
+	"server.port":              "8080",
+	"server.read_timeout":      5 * time.Second,
+	"server.write_timeout":     10 * time.Second,
+	"server.idle_timeout":      120 * time.Second,
+	"server.openapi_path":      "./api/openapi.yaml",
+	"server.crash_dump_dir":    "./crash-dumps",
+	"server.readiness_timeout": 3 * time.Second,
+	"server.shutdown_delay":    5 * time.Second,
+
+	"database.host":               "localhost",
+	"database.port":               5432,
+	"database.user":               "postgres",
+	"database.password":           "",
+	"database.dbname":             "cat-db",
+	"database.sslmode":            "disable",
+	"database.sslrootcert":        "",
+	"database.application_name":   "spy-cat-agency",
+	"database.statement_timeout":  30 * time.Second,
+	"database.max_open_conns":     25,
+	"database.max_idle_conns":     25,
+	"database.conn_max_lifetime":  30 * time.Minute,
+	"database.conn_max_idle_time": 5 * time.Minute,
+	"database.connect_timeout":    time.Minute,
+	"database.migrations_path":    "./migrations",
+	"database.auto_migrate":       true,
+
+	"database.replicas":               []string{},
+	"database.replica_check_interval": 5 * time.Second,
+	"database.replica_max_lag":        10 * time.Second,
+
+	"log.level":          "info",
+	"log.max_body_bytes": 4096,
+	"log.sample_rate":    1.0,
+	"log.redact": []string{
+		"$..salary",
+		"$..new_salary",
+		"$..notes",
+		"$.key",
+		"$.secret",
+		"$.payload",
+		"$.deliveries[*].payload",
+	},
+
+	"tracing.exporter":     "none",
+	"tracing.endpoint":     "localhost:4318",
+	"tracing.insecure":     true,
+	"tracing.file_path":    "./traces.jsonl",
+	"tracing.sample_ratio": 1.0,
+	"tracing.service_name": "spy-cat-agency",
+
+	"breed_catalog.url":       "https://api.thecatapi.com/v1/breeds",
+	"breed_catalog.timeout":   5 * time.Second,
+	"breed_catalog.cache_ttl": time.Hour,
+
+	"auth.algorithm":        "HS256",
+	"auth.secret":           "",
+	"auth.public_key_path":  "",
+	"auth.private_key_path": "",
+	"auth.issuer":           "spy-cat-agency",
+	"auth.audience":         "spy-cat-agency-api",
+	"auth.leeway":           30 * time.Second,
+
+	"rate_limit.enabled": true,
+	"rate_limit.rate":    10.0,
+	"rate_limit.burst":   20,
+	"rate_limit.routes": map[string]any{
+		"post /api/v1/cats": map[string]any{"rate": 0.2, "burst": 5},
+	},
+	"rate_limit.max_in_flight": 200,
+	"rate_limit.retry_after":   time.Second,
+
+	"rate_limit.auth_failure_rate":  0.1,
+	"rate_limit.auth_failure_burst": 10,
+
+	"rate_limit.max_connections_per_client": 20,
+
+	"idempotency.ttl":              24 * time.Hour,
+	"idempotency.lease":            time.Minute,
+	"idempotency.cleanup_interval": time.Hour,
+
+	"outbox.poll_interval":     time.Second,
+	"outbox.batch_size":        100,
+	"outbox.retry_backoff":     time.Second,
+	"outbox.max_retry_backoff": 5 * time.Minute,
+	"outbox.max_attempts":      20,
+	"outbox.retention":         7 * 24 * time.Hour,
+	"outbox.cleanup_interval":  time.Hour,
+
+	"webhooks.timeout":           10 * time.Second,
+	"webhooks.poll_interval":     time.Second,
+	"webhooks.batch_size":        20,
+	"webhooks.max_attempts":      8,
+	"webhooks.retry_backoff":     10 * time.Second,
+	"webhooks.max_retry_backoff": time.Hour,
+
+	"stream.max_clients":        500,
+	"stream.buffer_size":        1000,
+	"stream.client_buffer":      64,
+	"stream.heartbeat_interval": 15 * time.Second,
+
+	"websocket.max_unacked":         100,
+	"websocket.max_message_bytes":   64 << 10,
+	"websocket.ping_interval":       30 * time.Second,
+	"websocket.write_timeout":       10 * time.Second,
+	"websocket.auth_check_interval": time.Minute,
+
+	"changes.retention":        30 * 24 * time.Hour,
+	"changes.cleanup_interval": time.Hour,
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	port, err := strconv.Atoi(c.Server.Port)
+	v.check(err == nil && port > 0 && port <= 65535, "server.port: must be a number between 1 and 65535, got %q", c.Server.Port)
+	v.check(c.Server.ReadTimeout >= 0, "server.read_timeout: must not be negative")
+	v.check(c.Server.WriteTimeout >= 0, "server.write_timeout: must not be negative")
+	v.check(c.Server.IdleTimeout >= 0, "server.idle_timeout: must not be negative")
+	v.file(c.Server.OpenAPIPath, "server.openapi_path")
+	v.check(c.Server.ReadinessTimeout > 0, "server.readiness_timeout: must be positive")
+	v.check(c.Server.ShutdownDelay >= 0, "server.shutdown_delay: must not be negative")
+
+	v.required(c.Database.Host, "database.host")
+	v.check(c.Database.Port > 0 && c.Database.Port <= 65535, "database.port: must be between 1 and 65535, got %d", c.Database.Port)
+	v.required(c.Database.User, "database.user")
+	v.required(c.Database.Password, "database.password")
+	v.required(c.Database.DBName, "database.dbname")
+	switch c.Database.SSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		v.add("database.sslmode: must be one of disable, require, verify-ca or verify-full, got %q", c.Database.SSLMode)
+	}
+	if c.Database.SSLRootCert != "" {
+		v.file(c.Database.SSLRootCert, "database.sslrootcert")
+	}
+	v.check(c.Database.StatementTimeout >= 0, "database.statement_timeout: must not be negative")
+	v.check(c.Database.MaxOpenConns >= 0, "database.max_open_conns: must not be negative")
+	v.check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns: must not be negative")
+	v.check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns: must not exceed database.max_open_conns")
+	v.check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime: must not be negative")
+	v.check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time: must not be negative")
+	v.check(c.Database.ConnectTimeout > 0, "database.connect_timeout: must be positive")
+	for i, dsn := range c.Database.Replicas {
+		u, err := url.Parse(dsn)
+		v.check(err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") && u.Host != "",
+			"database.replicas[%d]: must be a postgres:// connection URL", i)
+	}
+	v.check(c.Database.ReplicaCheckInterval > 0, "database.replica_check_interval: must be positive")
+	v.check(c.Database.ReplicaMaxLag >= 0, "database.replica_max_lag: must not be negative")
+	v.file(c.Database.MigrationsPath, "database.migrations_path")
+
+	var level slog.Level
+	v.check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level: must be one of debug, info, warn or error, got %q", c.Log.Level)
+	v.check(c.Log.MaxBodyBytes >= 0, "log.max_body_bytes: must not be negative")
+	v.check(c.Log.SampleRate >= 0 && c.Log.SampleRate <= 1, "log.sample_rate: must be between 0 and 1, got %v", c.Log.SampleRate)
+
+	switch c.Tracing.Exporter {
+	case "none":
+	case "otlp":
+		v.required(c.Tracing.Endpoint, "tracing.endpoint")
+	case "stdout":
+	case "file":
+		v.required(c.Tracing.FilePath, "tracing.file_path")
+	default:
+		v.add("tracing.exporter: must be one of none, otlp, stdout or file, got %q", c.Tracing.Exporter)
+	}
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio: must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	v.required(c.Tracing.ServiceName, "tracing.service_name")
+
+	u, err := url.Parse(c.BreedCatalog.URL)
+	v.check(err == nil && u.IsAbs(), "breed_catalog.url: must be an absolute URL, got %q", c.BreedCatalog.URL)
+	v.check(c.BreedCatalog.Timeout > 0, "breed_catalog.timeout: must be positive")
+	v.check(c.BreedCatalog.CacheTTL >= 0, "breed_catalog.cache_ttl: must not be negative")
+
+	switch c.Auth.Algorithm {
+	case "HS256":
+		v.check(len(c.Auth.Secret) >= 32, "auth.secret: must be at least 32 bytes for HS256")
+	case "RS256":
+		v.file(c.Auth.PublicKeyPath, "auth.public_key_path")
+	default:
+		v.add("auth.algorithm: must be HS256 or RS256, got %q", c.Auth.Algorithm)
+	}
+	v.check(c.Auth.Leeway >= 0, "auth.leeway: must not be negative")
+
+	v.check(c.RateLimit.Rate > 0, "rate_limit.rate: must be positive")
+	v.check(c.RateLimit.Burst >= 1, "rate_limit.burst: must be at least 1")
+	for route, limit := range c.RateLimit.Routes {
+		method, path, ok := strings.Cut(route, " ")
+		v.check(ok && method != "" && strings.HasPrefix(path, "/"),
+			"rate_limit.routes: %q must be a method and a route template, e.g. \"post /api/v1/cats\"", route)
+		v.check(limit.Rate > 0, "rate_limit.routes[%s].rate: must be positive", route)
+		v.check(limit.Burst >= 1, "rate_limit.routes[%s].burst: must be at least 1", route)
+	}
+	v.check(c.RateLimit.MaxInFlight >= 0, "rate_limit.max_in_flight: must not be negative")
+	v.check(c.RateLimit.RetryAfter > 0, "rate_limit.retry_after: must be positive")
+	v.check(c.RateLimit.AuthFailureRate > 0, "rate_limit.auth_failure_rate: must be positive")
+	v.check(c.RateLimit.AuthFailureBurst >= 1, "rate_limit.auth_failure_burst: must be at least 1")
+	v.check(c.RateLimit.MaxConnectionsPerClient >= 0, "rate_limit.max_connections_per_client: must not be negative")
+
+	v.check(c.Idempotency.TTL > 0, "idempotency.ttl: must be positive")
+	v.check(c.Idempotency.Lease > 0, "idempotency.lease: must be positive")
+	v.check(c.Idempotency.Lease < c.Idempotency.TTL, "idempotency.lease: must be less than idempotency.ttl")
+	v.check(c.Idempotency.Lease >= c.Server.WriteTimeout, "idempotency.lease: must not be less than server.write_timeout")
+	v.check(c.Idempotency.CleanupInterval > 0, "idempotency.cleanup_interval: must be positive")
+
+	v.check(c.Outbox.PollInterval > 0, "outbox.poll_interval: must be positive")
+	v.check(c.Outbox.BatchSize > 0, "outbox.batch_size: must be positive")
+	v.check(c.Outbox.RetryBackoff > 0, "outbox.retry_backoff: must be positive")
+	v.check(c.Outbox.MaxRetryBackoff >= c.Outbox.RetryBackoff, "outbox.max_retry_backoff: must not be less than outbox.retry_backoff")
+	v.check(c.Outbox.MaxAttempts >= 1, "outbox.max_attempts: must be at least 1")
+	v.check(c.Outbox.Retention > 0, "outbox.retention: must be positive")
+	v.check(c.Outbox.CleanupInterval > 0, "outbox.cleanup_interval: must be positive")
+
+	v.check(c.Webhooks.Timeout > 0, "webhooks.timeout: must be positive")
+	v.check(c.Webhooks.PollInterval > 0, "webhooks.poll_interval: must be positive")
+	v.check(c.Webhooks.BatchSize > 0, "webhooks.batch_size: must be positive")
+	v.check(c.Webhooks.MaxAttempts >= 1, "webhooks.max_attempts: must be at least 1")
+	v.check(c.Webhooks.RetryBackoff > 0, "webhooks.retry_backoff: must be positive")
+	v.check(c.Webhooks.MaxRetryBackoff >= c.Webhooks.RetryBackoff, "webhooks.max_retry_backoff: must not be less than webhooks.retry_backoff")
+
+	v.check(c.Stream.MaxClients > 0, "stream.max_clients: must be positive")
+	v.check(c.Stream.BufferSize > 0, "stream.buffer_size: must be positive")
+	v.check(c.Stream.ClientBuffer > 0, "stream.client_buffer: must be positive")
+	v.check(c.Stream.HeartbeatInterval > 0, "stream.heartbeat_interval: must be positive")
+
+	v.check(c.WebSocket.MaxUnacked > 0, "websocket.max_unacked: must be positive")
+	v.check(c.WebSocket.MaxMessageBytes > 0, "websocket.max_message_bytes: must be positive")
+	v.check(c.WebSocket.PingInterval > 0, "websocket.ping_interval: must be positive")
+	v.check(c.WebSocket.WriteTimeout > 0, "websocket.write_timeout: must be positive")
+	v.check(c.WebSocket.AuthCheckInterval > 0, "websocket.auth_check_interval: must be positive")
+
+	v.check(c.Changes.Retention > 0, "changes.retention: must be positive")
+	v.check(c.Changes.CleanupInterval > 0, "changes.cleanup_interval: must be positive")
+
+	return v.err()
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.add(format, args...)
+	}
+}
+
+func (v *validator) required(value, key string) {
+	v.check(value != "", "%s: is required", key)
+}
+
+func (v *validator) file(path, key string) {
+	if path == "" {
+		v.add("%s: is required", key)
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		v.add("%s: %v", key, err)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"errors"
+	"github.com/spf13/pflag"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig loads the shipped config.yaml and fills in what a deployment has to provide.
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+
+	c, err := Load(pflag.NewFlagSet("test", pflag.ContinueOnError), []string{"--config", "config.yaml"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	c.Server.OpenAPIPath = "../api/openapi.yaml"
+	c.Database.MigrationsPath = "../migrations"
+	c.Database.Password = "secret"
+	c.Auth.Algorithm = "HS256"
+	c.Auth.Secret = strings.Repeat("s", 32)
+
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig(t).Validate(); err != nil {
+		t.Fatalf("Validate() of the valid configuration: %v", err)
+	}
+}
+
+func TestValidateFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string
+	}{
+		{
+			name:   "port out of range",
+			modify: func(c *Config) { c.Server.Port = "70000" },
+			want:   []string{`server.port: must be a number between 1 and 65535, got "70000"`},
+		},
+		{
+			name:   "port not a number",
+			modify: func(c *Config) { c.Server.Port = "http" },
+			want:   []string{`server.port: must be a number between 1 and 65535, got "http"`},
+		},
+		{
+			name:   "missing file",
+			modify: func(c *Config) { c.Server.OpenAPIPath = "" },
+			want:   []string{"server.openapi_path: is required"},
+		},
+		{
+			name:   "required database settings",
+			modify: func(c *Config) { c.Database.Host, c.Database.Password = "", "" },
+			want:   []string{"database.host: is required", "database.password: is required"},
+		},
+		{
+			name:   "unknown sslmode",
+			modify: func(c *Config) { c.Database.SSLMode = "prefer" },
+			want:   []string{`database.sslmode: must be one of disable, require, verify-ca or verify-full, got "prefer"`},
+		},
+		{
+			name:   "more idle than open connections",
+			modify: func(c *Config) { c.Database.MaxOpenConns, c.Database.MaxIdleConns = 5, 10 },
+			want:   []string{"database.max_idle_conns: must not exceed database.max_open_conns"},
+		},
+		{
+			name:   "replica that is not a postgres URL",
+			modify: func(c *Config) { c.Database.Replicas = []string{"mysql://replica:3306/db"} },
+			want:   []string{"database.replicas[0]: must be a postgres:// connection URL"},
+		},
+		{
+			name:   "negative replica lag",
+			modify: func(c *Config) { c.Database.ReplicaMaxLag = -time.Second },
+			want:   []string{"database.replica_max_lag: must not be negative"},
+		},
+		{
+			name:   "unknown log level",
+			modify: func(c *Config) { c.Log.Level = "verbose" },
+			want:   []string{`log.level: must be one of debug, info, warn or error, got "verbose"`},
+		},
+		{
+			name:   "sample rate above 1",
+			modify: func(c *Config) { c.Log.SampleRate = 1.5 },
+			want:   []string{"log.sample_rate: must be between 0 and 1, got 1.5"},
+		},
+		{
+			name:   "otlp without endpoint",
+			modify: func(c *Config) { c.Tracing.Exporter, c.Tracing.Endpoint = "otlp", "" },
+			want:   []string{"tracing.endpoint: is required"},
+		},
+		{
+			name:   "relative breed catalog URL",
+			modify: func(c *Config) { c.BreedCatalog.URL = "/v1/breeds" },
+			want:   []string{`breed_catalog.url: must be an absolute URL, got "/v1/breeds"`},
+		},
+		{
+			name:   "short HS256 secret",
+			modify: func(c *Config) { c.Auth.Secret = "short" },
+			want:   []string{"auth.secret: must be at least 32 bytes for HS256"},
+		},
+		{
+			name:   "unknown algorithm",
+			modify: func(c *Config) { c.Auth.Algorithm = "none" },
+			want:   []string{`auth.algorithm: must be HS256 or RS256, got "none"`},
+		},
+		{
+			name: "malformed route limit",
+			modify: func(c *Config) {
+				c.RateLimit.Routes = map[string]RouteRate{"cats": {Rate: 0, Burst: 0}}
+			},
+			want: []string{
+				`rate_limit.routes: "cats" must be a method and a route template, e.g. "post /api/v1/cats"`,
+				"rate_limit.routes[cats].rate: must be positive",
+				"rate_limit.routes[cats].burst: must be at least 1",
+			},
+		},
+		{
+			name:   "negative connection limit",
+			modify: func(c *Config) { c.RateLimit.MaxConnectionsPerClient = -1 },
+			want:   []string{"rate_limit.max_connections_per_client: must not be negative"},
+		},
+		{
+			name: "idempotency lease outside the ttl and write timeout",
+			modify: func(c *Config) {
+				c.Idempotency.TTL = time.Minute
+				c.Idempotency.Lease = time.Hour
+				c.Server.WriteTimeout = 2 * time.Hour
+			},
+			want: []string{
+				"idempotency.lease: must be less than idempotency.ttl",
+				"idempotency.lease: must not be less than server.write_timeout",
+			},
+		},
+		{
+			name:   "outbox backoff below its start",
+			modify: func(c *Config) { c.Outbox.RetryBackoff, c.Outbox.MaxRetryBackoff = time.Minute, time.Second },
+			want:   []string{"outbox.max_retry_backoff: must not be less than outbox.retry_backoff"},
+		},
+		{
+			name:   "no outbox attempts",
+			modify: func(c *Config) { c.Outbox.MaxAttempts = 0 },
+			want:   []string{"outbox.max_attempts: must be at least 1"},
+		},
+		{
+			name:   "no stream clients",
+			modify: func(c *Config) { c.Stream.MaxClients = 0 },
+			want:   []string{"stream.max_clients: must be positive"},
+		},
+		{
+			name:   "no websocket auth check",
+			modify: func(c *Config) { c.WebSocket.AuthCheckInterval = 0 },
+			want:   []string{"websocket.auth_check_interval: must be positive"},
+		},
+		{
+			name:   "no change retention",
+			modify: func(c *Config) { c.Changes.Retention = 0 },
+			want:   []string{"changes.retention: must be positive"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig(t)
+			tt.modify(c)
+
+			var validationErr *ValidationError
+			if err := c.Validate(); !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+			}
+
+			for _, want := range tt.want {
+				if !slices.Contains(validationErr.Problems, want) {
+					t.Errorf("problems %q do not contain %q", validationErr.Problems, want)
+				}
+			}
+			if len(validationErr.Problems) != len(tt.want) {
+				t.Errorf("got %d problems %q, want %d", len(validationErr.Problems), validationErr.Problems, len(tt.want))
+			}
+		})
+	}
+}
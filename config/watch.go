@@ -0,0 +1,100 @@
+package config
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const reloadDelay = 250 * time.Millisecond
+
+var reloadableKeys = []string{
+	"log.",
+	"breed_catalog.cache_ttl",
+	"rate_limit.",
+}
+
+func reloadable(key string) bool {
+	for _, prefix := range reloadableKeys {
+		if key == prefix || strings.HasSuffix(prefix, ".") && strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) Watch(apply func(*Config) error) {
+	if c.v == nil || c.v.ConfigFileUsed() == "" {
+		slog.Info("No configuration file in use, hot reload disabled")
+		return
+	}
+
+	current := c.settings()
+
+	c.v.OnConfigChange(func(fsnotify.Event) {
+		// Editors often truncate and rewrite in separate steps; let the writes settle before reading again.
+		time.Sleep(reloadDelay)
+
+		if err := c.v.ReadInConfig(); err != nil {
+			slog.Error("Ignoring configuration change", "file", c.v.ConfigFileUsed(), "error", err)
+			return
+		}
+
+		next, err := decode(c.v)
+		if err == nil {
+			err = next.Validate()
+		}
+		if err != nil {
+			slog.Error("Ignoring configuration change", "file", c.v.ConfigFileUsed(), "error", err)
+			return
+		}
+
+		settings := next.settings()
+
+		var applied, restart []string
+		for key, value := range settings {
+			if reflect.DeepEqual(value, current[key]) {
+				continue
+			}
+			if reloadable(key) {
+				applied = append(applied, key)
+			} else {
+				restart = append(restart, key)
+			}
+		}
+		sort.Strings(applied)
+		sort.Strings(restart)
+
+		if len(restart) > 0 {
+			slog.Warn("Configuration change requires a restart", "keys", restart)
+		}
+
+		if len(applied) == 0 {
+			return
+		}
+
+		if err := apply(next); err != nil {
+			slog.Error("Failed to apply configuration change", "keys", applied, "error", err)
+			return
+		}
+
+		for _, key := range applied {
+			current[key] = settings[key]
+		}
+
+		slog.Info("Configuration reloaded", "keys", applied)
+	})
+
+	c.v.WatchConfig()
+}
+
+func (c *Config) settings() map[string]any {
+	settings := make(map[string]any, len(defaults))
+	for key := range defaults {
+		settings[key] = c.v.Get(key)
+	}
+	return settings
+}
@@ -0,0 +1,17 @@
+package apikey
+
+import (
+	"spy-cat-agency/internal/auth"
+	"time"
+)
+
+type APIKey struct {
+	ID         int
+	Name       string
+	Prefix     string
+	Scopes     []auth.Permission
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
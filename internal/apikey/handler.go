@@ -0,0 +1,115 @@
+package apikey
+
+import (
+	"github.com/gin-gonic/gin"
+	"spy-cat-agency/internal/auth"
+	"strconv"
+	"time"
+)
+
+type APIKeyResponse struct {
+	ID         int               `json:"id"`
+	Name       string            `json:"name"`
+	Prefix     string            `json:"prefix"`
+	Scopes     []auth.Permission `json:"scopes"`
+	CreatedAt  time.Time         `json:"created_at"`
+	ExpiresAt  *time.Time        `json:"expires_at"`
+	LastUsedAt *time.Time        `json:"last_used_at"`
+	RevokedAt  *time.Time        `json:"revoked_at"`
+}
+
+type ListAPIKeysResponse struct {
+	APIKeys []APIKeyResponse `json:"api_keys"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string            `json:"name"`
+	Scopes    []auth.Permission `json:"scopes"`
+	ExpiresAt *time.Time        `json:"expires_at"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+type Handler struct {
+	Service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{Service: service}
+}
+
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	keys, err := h.Service.ListAPIKeys(ctx)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := ListAPIKeysResponse{APIKeys: make([]APIKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		response.APIKeys = append(response.APIKeys, toResponse(&key))
+	}
+
+	c.JSON(200, response)
+}
+
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var request CreateAPIKeyRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	key, secret, err := h.Service.CreateAPIKey(ctx, request.Name, request.Scopes, request.ExpiresAt)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := CreateAPIKeyResponse{
+		APIKeyResponse: toResponse(key),
+		Key:            secret,
+	}
+
+	c.JSON(201, response)
+}
+
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	stringID := c.Param("id")
+	id, err := strconv.Atoi(stringID)
+	if err != nil {
+		_ = c.Error(NotFoundErr)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	err = h.Service.RevokeAPIKey(ctx, id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+func toResponse(key *APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
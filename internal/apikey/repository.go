@@ -0,0 +1,120 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/db"
+)
+
+type Repository struct {
+	conn *db.DB
+}
+
+func NewRepository(conn *db.DB) *Repository {
+	return &Repository{conn: conn}
+}
+
+const columns = `id, name, prefix, scopes, created_at, expires_at, last_used_at, revoked_at`
+
+func (r *Repository) CreateAPIKey(ctx context.Context, key *APIKey, hash string) (int, error) {
+	query := `INSERT INTO api_keys (name, prefix, key_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+
+	scopes := make([]string, len(key.Scopes))
+	for i, s := range key.Scopes {
+		scopes[i] = string(s)
+	}
+
+	err := r.conn.QueryRowContext(ctx, query, key.Name, key.Prefix, hash, pq.Array(scopes), key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return key.ID, nil
+}
+
+func (r *Repository) GetAllAPIKeys(ctx context.Context) ([]APIKey, error) {
+	query := `SELECT ` + columns + ` FROM api_keys ORDER BY id`
+
+	rows, err := r.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+
+	for rows.Next() {
+		key, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *Repository) GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	query := `SELECT ` + columns + ` FROM api_keys WHERE key_hash = $1`
+
+	return scan(r.conn.QueryRowContext(ctx, query, hash))
+}
+
+func (r *Repository) GetAPIKeyByID(ctx context.Context, id int) (*APIKey, error) {
+	query := `SELECT ` + columns + ` FROM api_keys WHERE id = $1`
+
+	return scan(r.conn.QueryRowContext(ctx, query, id))
+}
+
+func (r *Repository) RevokeAPIKey(ctx context.Context, id int) error {
+	query := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1`
+
+	res, err := r.conn.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *Repository) TouchAPIKey(ctx context.Context, id int) error {
+	query := `UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+
+	_, err := r.conn.ExecContext(ctx, query, id)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scan(row scanner) (*APIKey, error) {
+	var key APIKey
+	var scopes []string
+
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&scopes),
+		&key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = make([]auth.Permission, len(scopes))
+	for i, s := range scopes {
+		key.Scopes[i] = auth.Permission(s)
+	}
+
+	return &key, nil
+}
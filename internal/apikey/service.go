@@ -0,0 +1,163 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/db"
+	"spy-cat-agency/internal/tracing"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	keyPrefix  = "sca_"
+	prefixSize = len(keyPrefix) + 8
+)
+
+var (
+	NotFoundErr     = errors.New("api key not found")
+	InvalidScopeErr = errors.New("unknown scope")
+	InvalidKeyErr   = errors.New("invalid api key")
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+func (s *Service) CreateAPIKey(ctx context.Context, name string, scopes []auth.Permission, expiresAt *time.Time) (*APIKey, string, error) {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "apikey.Service.CreateAPIKey")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.APIKeysManage); err != nil {
+		return nil, "", err
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(auth.Scopes, scope) {
+			return nil, "", fmt.Errorf("%w %q", InvalidScopeErr, scope)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	key := keyPrefix + hex.EncodeToString(secret)
+
+	apiKey := &APIKey{
+		Name:      name,
+		Prefix:    key[:prefixSize],
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		ExpiresAt: expiresAt,
+	}
+
+	if _, err := s.repo.CreateAPIKey(ctx, apiKey, hash(key)); err != nil {
+		return nil, "", err
+	}
+
+	return apiKey, key, nil
+}
+
+func (s *Service) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	ctx, span := tracing.Start(ctx, "apikey.Service.ListAPIKeys")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.APIKeysManage); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetAllAPIKeys(ctx)
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, id int) error {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "apikey.Service.RevokeAPIKey")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.APIKeysManage); err != nil {
+		return err
+	}
+
+	err := s.repo.RevokeAPIKey(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFoundErr
+	}
+
+	return err
+}
+
+func (s *Service) Authenticate(ctx context.Context, key string) (*auth.Principal, error) {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "apikey.Service.Authenticate")
+	defer span.End()
+
+	if !strings.HasPrefix(key, keyPrefix) {
+		return nil, InvalidKeyErr
+	}
+
+	apiKey, err := s.repo.GetAPIKeyByHash(ctx, hash(key))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, InvalidKeyErr
+		}
+		return nil, err
+	}
+
+	if err = usable(apiKey); err != nil {
+		return nil, err
+	}
+
+	if err = s.repo.TouchAPIKey(ctx, apiKey.ID); err != nil {
+		slog.WarnContext(ctx, "Failed to record API key usage", "api_key_id", apiKey.ID, "error", err)
+	}
+
+	return &auth.Principal{
+		Subject:   "api_key:" + strconv.Itoa(apiKey.ID),
+		Scopes:    apiKey.Scopes,
+		APIKeyID:  &apiKey.ID,
+		ExpiresAt: apiKey.ExpiresAt,
+	}, nil
+}
+
+// Check reports whether the key with id can still be used, for connections that outlive the request they
+// were authenticated with. It returns InvalidKeyErr once the key has been revoked or has expired.
+func (s *Service) Check(ctx context.Context, id int) error {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "apikey.Service.Check")
+	defer span.End()
+
+	apiKey, err := s.repo.GetAPIKeyByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return InvalidKeyErr
+		}
+		return err
+	}
+
+	return usable(apiKey)
+}
+
+func usable(apiKey *APIKey) error {
+	if apiKey.RevokedAt != nil {
+		return fmt.Errorf("%w: revoked", InvalidKeyErr)
+	}
+	if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+		return fmt.Errorf("%w: expired", InvalidKeyErr)
+	}
+	return nil
+}
+
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
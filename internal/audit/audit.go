@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+const (
+	EntityCat     = "cat"
+	EntityMission = "mission"
+	EntityTarget  = "target"
+)
+
+type Event struct {
+	ID         int64
+	OccurredAt time.Time
+	Actor      string
+	RequestID  string
+	EntityType string
+	EntityID   int
+	Action     Action
+	Before     json.RawMessage
+	After      json.RawMessage
+}
+
+type Filter struct {
+	EntityType string
+	EntityID   *int
+	Actor      string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+}
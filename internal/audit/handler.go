@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+type ListEventsRequest struct {
+	EntityType string     `form:"entity_type"`
+	EntityID   *int       `form:"entity_id"`
+	Actor      string     `form:"actor"`
+	From       *time.Time `form:"from"`
+	To         *time.Time `form:"to"`
+	Limit      int        `form:"limit"`
+}
+
+type EventResponse struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Actor      string          `json:"actor"`
+	RequestID  string          `json:"request_id,omitempty"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int             `json:"entity_id"`
+	Action     Action          `json:"action"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+}
+
+type ListEventsResponse struct {
+	Events []EventResponse `json:"events"`
+}
+
+type Handler struct {
+	Service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{Service: service}
+}
+
+func (h *Handler) ListEvents(c *gin.Context) {
+	var request ListEventsRequest
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	events, err := h.Service.ListEvents(ctx, Filter{
+		EntityType: request.EntityType,
+		EntityID:   request.EntityID,
+		Actor:      request.Actor,
+		From:       request.From,
+		To:         request.To,
+		Limit:      request.Limit,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := ListEventsResponse{Events: make([]EventResponse, 0, len(events))}
+	for _, e := range events {
+		response.Events = append(response.Events, EventResponse{
+			ID:         e.ID,
+			OccurredAt: e.OccurredAt,
+			Actor:      e.Actor,
+			RequestID:  e.RequestID,
+			EntityType: e.EntityType,
+			EntityID:   e.EntityID,
+			Action:     e.Action,
+			Before:     e.Before,
+			After:      e.After,
+		})
+	}
+
+	c.JSON(200, response)
+}
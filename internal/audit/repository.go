@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"spy-cat-agency/internal/db"
+	"strings"
+)
+
+type Repository struct {
+	conn *db.DB
+}
+
+func NewRepository(conn *db.DB) *Repository {
+	return &Repository{conn: conn}
+}
+
+func (r *Repository) CreateEvent(ctx context.Context, e *Event) error {
+	query := `
+		INSERT INTO audit_events (actor, request_id, entity_type, entity_id, action, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, occurred_at`
+
+	requestID := sql.NullString{String: e.RequestID, Valid: e.RequestID != ""}
+
+	return r.conn.QueryRowContext(ctx, query, e.Actor, requestID, e.EntityType, e.EntityID, e.Action,
+		jsonb(e.Before), jsonb(e.After)).Scan(&e.ID, &e.OccurredAt)
+}
+
+func (r *Repository) GetEvents(ctx context.Context, f Filter) ([]Event, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.EntityType != "" {
+		add("entity_type = $%d", f.EntityType)
+	}
+	if f.EntityID != nil {
+		add("entity_id = $%d", *f.EntityID)
+	}
+	if f.Actor != "" {
+		add("actor = $%d", f.Actor)
+	}
+	if f.From != nil {
+		add("occurred_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("occurred_at < $%d", *f.To)
+	}
+
+	query := `SELECT id, occurred_at, actor, request_id, entity_type, entity_id, action, before, after FROM audit_events`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, f.Limit)
+	query += fmt.Sprintf(" ORDER BY occurred_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := r.conn.Reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0)
+
+	for rows.Next() {
+		var e Event
+		var requestID sql.NullString
+		var before, after []byte
+		err = rows.Scan(&e.ID, &e.OccurredAt, &e.Actor, &requestID, &e.EntityType, &e.EntityID, &e.Action, &before, &after)
+		if err != nil {
+			return nil, err
+		}
+		e.RequestID, e.Before, e.After = requestID.String, before, after
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// jsonb passes a document as text: lib/pq would send a []byte as bytea, which Postgres rejects for jsonb.
+func jsonb(doc json.RawMessage) any {
+	if doc == nil {
+		return nil
+	}
+	return string(doc)
+}
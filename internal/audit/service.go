@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/requestid"
+	"spy-cat-agency/internal/tracing"
+)
+
+const defaultLimit = 100
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Record appends an event for a change made by the principal in ctx. before is nil for creates and after
+// is nil for deletes; for updates only the fields that differ between the two are kept. Call it with the
+// context of the transaction that makes the change, so the event is stored if and only if the change is.
+func (s *Service) Record(ctx context.Context, entityType string, entityID int, action Action, before, after any) error {
+	ctx, span := tracing.Start(ctx, "audit.Service.Record")
+	defer span.End()
+
+	actor := "system"
+	if p := auth.FromContext(ctx); p != nil {
+		actor = p.Subject
+	}
+
+	b, a, err := diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	event := &Event{
+		Actor:      actor,
+		RequestID:  requestid.FromContext(ctx),
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Before:     b,
+		After:      a,
+	}
+
+	return s.repo.CreateEvent(ctx, event)
+}
+
+func (s *Service) ListEvents(ctx context.Context, f Filter) ([]Event, error) {
+	ctx, span := tracing.Start(ctx, "audit.Service.ListEvents")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.AuditRead); err != nil {
+		return nil, err
+	}
+
+	if f.Limit <= 0 {
+		f.Limit = defaultLimit
+	}
+
+	return s.repo.GetEvents(ctx, f)
+}
+
+func diff(before, after any) (json.RawMessage, json.RawMessage, error) {
+	old, err := fields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updated, err := fields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if old != nil && updated != nil {
+		for k, v := range old {
+			if w, ok := updated[k]; ok && reflect.DeepEqual(v, w) {
+				delete(old, k)
+				delete(updated, k)
+			}
+		}
+	}
+
+	b, err := marshal(old)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a, err := marshal(updated)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, a, nil
+}
+
+func fields(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]any
+	if err = json.Unmarshal(doc, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func marshal(m map[string]any) (json.RawMessage, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ForbiddenErr = errors.New("forbidden")
+
+type Permission string
+
+const (
+	CatsRead       Permission = "cats:read"
+	CatsWrite      Permission = "cats:write"
+	MissionsRead   Permission = "missions:read"
+	MissionsWrite  Permission = "missions:write"
+	TargetsUpdate  Permission = "targets:update"
+	APIKeysManage  Permission = "api_keys:manage"
+	AuditRead      Permission = "audit:read"
+	WebhooksManage Permission = "webhooks:manage"
+)
+
+// Scopes are the permissions that may be granted to API keys.
+var Scopes = []Permission{CatsRead, CatsWrite, MissionsRead, MissionsWrite, TargetsUpdate, AuditRead}
+
+var grants = map[Role][]Permission{
+	RoleAdmin:      {CatsRead, CatsWrite, MissionsRead, MissionsWrite, TargetsUpdate, APIKeysManage, AuditRead, WebhooksManage},
+	RoleHandler:    {CatsRead, MissionsRead, MissionsWrite, TargetsUpdate},
+	RoleFieldAgent: {MissionsRead, TargetsUpdate},
+}
+
+func (p *Principal) Can(perm Permission) bool {
+	if p.Scopes != nil {
+		return slices.Contains(p.Scopes, perm)
+	}
+	return slices.Contains(grants[p.Role], perm)
+}
+
+// Restricted reports whether the principal may only see and act on the mission assigned to its own cat.
+func (p *Principal) Restricted() bool {
+	return p.Role == RoleFieldAgent
+}
+
+func (p *Principal) IsCat(catID *int) bool {
+	return p.CatID != nil && catID != nil && *p.CatID == *catID
+}
+
+func Authorize(ctx context.Context, perm Permission) (*Principal, error) {
+	p := FromContext(ctx)
+	if p == nil || !p.Can(perm) {
+		return nil, fmt.Errorf("%w: %s permission required", ForbiddenErr, perm)
+	}
+	return p, nil
+}
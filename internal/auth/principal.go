@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	InvalidRoleErr = errors.New("unknown role")
+	MissingCatErr  = errors.New("field agents must have a cat_id")
+)
+
+type Role string
+
+const (
+	RoleAdmin      Role = "admin"
+	RoleHandler    Role = "handler"
+	RoleFieldAgent Role = "field_agent"
+)
+
+var roles = []Role{RoleAdmin, RoleHandler, RoleFieldAgent}
+
+func (r Role) Valid() bool {
+	return slices.Contains(roles, r)
+}
+
+type Principal struct {
+	Subject string
+	Role    Role
+	CatID   *int
+	Scopes  []Permission
+
+	// APIKeyID is set for API keys, which can be revoked while a connection authenticated with one is open.
+	APIKeyID  *int
+	ExpiresAt *time.Time
+}
+
+type principalKey struct{}
+
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+func (p *Principal) validate() error {
+	if !p.Role.Valid() {
+		return InvalidRoleErr
+	}
+	if p.Role == RoleFieldAgent && p.CatID == nil {
+		return MissingCatErr
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"spy-cat-agency/config"
+	"time"
+)
+
+var InvalidTokenErr = errors.New("invalid token")
+
+type Claims struct {
+	jwt.RegisteredClaims
+	Role  Role `json:"role"`
+	CatID *int `json:"cat_id,omitempty"`
+}
+
+type Verifier struct {
+	key    any
+	parser *jwt.Parser
+}
+
+func NewVerifier(cfg config.AuthConfig) (*Verifier, error) {
+	var key any
+	switch cfg.Algorithm {
+	case jwt.SigningMethodHS256.Name:
+		key = []byte(cfg.Secret)
+	case jwt.SigningMethodRS256.Name:
+		pem, err := os.ReadFile(cfg.PublicKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		key, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("auth.public_key_path: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", cfg.Algorithm)
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{cfg.Algorithm}),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.Audience),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithExpirationRequired(),
+	)
+
+	return &Verifier{key: key, parser: parser}, nil
+}
+
+func (v *Verifier) Verify(token string) (*Principal, error) {
+	var claims Claims
+	_, err := v.parser.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return v.key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", InvalidTokenErr, err)
+	}
+
+	p := &Principal{Subject: claims.Subject, Role: claims.Role, CatID: claims.CatID, ExpiresAt: &claims.ExpiresAt.Time}
+	if err = p.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", InvalidTokenErr, err)
+	}
+
+	return p, nil
+}
+
+type Signer struct {
+	method   jwt.SigningMethod
+	key      crypto.PrivateKey
+	issuer   string
+	audience string
+}
+
+func NewSigner(cfg config.AuthConfig) (*Signer, error) {
+	s := &Signer{issuer: cfg.Issuer, audience: cfg.Audience}
+
+	switch cfg.Algorithm {
+	case jwt.SigningMethodHS256.Name:
+		if cfg.Secret == "" {
+			return nil, errors.New("auth.secret is required to sign HS256 tokens")
+		}
+		s.method, s.key = jwt.SigningMethodHS256, []byte(cfg.Secret)
+	case jwt.SigningMethodRS256.Name:
+		if cfg.PrivateKeyPath == "" {
+			return nil, errors.New("auth.private_key_path is required to sign RS256 tokens")
+		}
+		pem, err := os.ReadFile(cfg.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("auth.private_key_path: %w", err)
+		}
+		s.method, s.key = jwt.SigningMethodRS256, key
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", cfg.Algorithm)
+	}
+
+	return s, nil
+}
+
+func (s *Signer) Sign(p *Principal, ttl time.Duration) (string, error) {
+	if err := p.validate(); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   p.Subject,
+			Audience:  jwt.ClaimStrings{s.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Role:  p.Role,
+		CatID: p.CatID,
+	}
+
+	return jwt.NewWithClaims(s.method, claims).SignedString(s.key)
+}
@@ -0,0 +1,144 @@
+package cat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"io"
+	"net/http"
+	"spy-cat-agency/internal/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Breed struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type BreedCatalog struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	ttl       time.Duration
+	breeds    []Breed
+	fetchedAt time.Time
+	pingErr   error
+	pingedAt  time.Time
+}
+
+func NewBreedCatalog(url string, timeout, ttl time.Duration) *BreedCatalog {
+	return &BreedCatalog{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+		ttl:    ttl,
+	}
+}
+
+func (b *BreedCatalog) SetTTL(ttl time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.ttl = ttl
+}
+
+func (b *BreedCatalog) Contains(ctx context.Context, breed string) (bool, error) {
+	breeds, err := b.Breeds(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, br := range breeds {
+		if strings.EqualFold(br.Name, breed) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Ping reports whether the catalog can be loaded. It reuses the cached breeds, and a failure is remembered
+// for the cache TTL too, so readiness probes do not call the catalog on every request.
+func (b *BreedCatalog) Ping(ctx context.Context) error {
+	b.mu.Lock()
+	if b.pingErr != nil && time.Since(b.pingedAt) < b.ttl {
+		err := b.pingErr
+		b.mu.Unlock()
+		return err
+	}
+	b.mu.Unlock()
+
+	_, err := b.Breeds(ctx)
+
+	b.mu.Lock()
+	b.pingErr, b.pingedAt = err, time.Now()
+	b.mu.Unlock()
+
+	return err
+}
+
+func (b *BreedCatalog) Breeds(ctx context.Context) ([]Breed, error) {
+	b.mu.Lock()
+	if b.breeds != nil && time.Since(b.fetchedAt) < b.ttl {
+		breeds := b.breeds
+		b.mu.Unlock()
+		return breeds, nil
+	}
+	b.mu.Unlock()
+
+	breeds, err := b.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	b.breeds, b.fetchedAt = breeds, time.Now()
+	b.mu.Unlock()
+
+	return breeds, nil
+}
+
+func (b *BreedCatalog) fetch(ctx context.Context) (breeds []Breed, err error) {
+	start := time.Now()
+	defer func() {
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+			metrics.BreedCatalogErrors.Inc()
+		}
+		metrics.BreedCatalogDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("breed catalog responded with status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(body, &breeds)
+	if err != nil {
+		return nil, err
+	}
+
+	return breeds, nil
+}
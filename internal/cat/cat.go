@@ -9,4 +9,5 @@ type Cat struct {
 	Breed             string    `json:"breed"`
 	Salary            float64   `json:"salary"`
 	CreatedAt         time.Time `json:"created_at"`
+	Version           int       `json:"version"`
 }
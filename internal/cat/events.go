@@ -0,0 +1,19 @@
+package cat
+
+// HiredEvent leaves out the salary: events reach every webhook partner and dashboard.
+type HiredEvent struct {
+	CatID             int    `json:"cat_id"`
+	Name              string `json:"name"`
+	Breed             string `json:"breed"`
+	YearsOfExperience int    `json:"years_of_experience"`
+}
+
+type SalaryChangedEvent struct {
+	CatID     int     `json:"cat_id"`
+	OldSalary float64 `json:"old_salary"`
+	NewSalary float64 `json:"new_salary"`
+}
+
+type DeletedEvent struct {
+	CatID int `json:"cat_id"`
+}
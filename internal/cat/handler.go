@@ -1,8 +1,8 @@
 package cat
 
 import (
-	"errors"
 	"github.com/gin-gonic/gin"
+	"spy-cat-agency/internal/etag"
 	"strconv"
 )
 
@@ -19,10 +19,10 @@ type CatResponse struct {
 }
 
 type CreateCatRequest struct {
-	Name              string  `json:"name" binding:"required"`
-	Breed             string  `json:"breed" binding:"required"`
-	YearsOfExperience int     `json:"years_of_experience" binding:"required"`
-	Salary            float64 `json:"salary" binding:"required"`
+	Name              string  `json:"name"`
+	Breed             string  `json:"breed"`
+	YearsOfExperience int     `json:"years_of_experience"`
+	Salary            float64 `json:"salary"`
 }
 
 type CreateCatResponse struct {
@@ -30,7 +30,7 @@ type CreateCatResponse struct {
 }
 
 type UpdateCatSalaryRequest struct {
-	Salary float64 `json:"salary" binding:"required"`
+	Salary float64 `json:"salary"`
 }
 
 type UpdateCatSalaryResponse struct {
@@ -47,9 +47,11 @@ func NewHandler(service *Service) *Handler {
 }
 
 func (h *Handler) ListCats(c *gin.Context) {
-	cats, err := h.Service.ListCats()
+	ctx := c.Request.Context()
+
+	cats, err := h.Service.ListCats(ctx)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server"})
+		_ = c.Error(err)
 		return
 	}
 
@@ -75,18 +77,15 @@ func (h *Handler) CreateCat(c *gin.Context) {
 	var catRequest CreateCatRequest
 	err := c.ShouldBindJSON(&catRequest)
 	if err != nil {
-		c.JSON(400, gin.H{"error": "The request body is invalid or missing required fields"})
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	id, err := h.Service.CreateCat(catRequest.Name, catRequest.Breed, catRequest.YearsOfExperience, catRequest.Salary)
+	ctx := c.Request.Context()
+
+	id, err := h.Service.CreateCat(ctx, catRequest.Name, catRequest.Breed, catRequest.YearsOfExperience, catRequest.Salary)
 	if err != nil {
-		switch {
-		case errors.Is(err, WrongBreedErr):
-			c.JSON(400, gin.H{"error": "The specified breed is not recognized."})
-			return
-		}
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server."})
+		_ = c.Error(err)
 		return
 	}
 
@@ -101,18 +100,19 @@ func (h *Handler) GetCat(c *gin.Context) {
 	stringID := c.Param("id")
 	id, err := strconv.Atoi(stringID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "The requested resource does not exist."})
+		_ = c.Error(NotFoundErr)
 		return
 	}
 
-	cat, err := h.Service.GetCat(id)
+	ctx := c.Request.Context()
+
+	cat, err := h.Service.GetCat(ctx, id)
 	if err != nil {
-		switch {
-		case errors.Is(err, NotFoundErr):
-			c.JSON(404, gin.H{"error": "The requested resource does not exist."})
-			return
-		}
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server."})
+		_ = c.Error(err)
+		return
+	}
+
+	if etag.NotModified(c, cat.Version) {
 		return
 	}
 
@@ -131,25 +131,27 @@ func (h *Handler) UpdateCat(c *gin.Context) {
 	var catRequest UpdateCatSalaryRequest
 	err := c.ShouldBindJSON(&catRequest)
 	if err != nil {
-		c.JSON(400, gin.H{"error": "The request body is invalid or missing required fields"})
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
 	stringID := c.Param("id")
 	id, err := strconv.Atoi(stringID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "The requested resource does not exist."})
+		_ = c.Error(NotFoundErr)
 		return
 	}
 
 	ctx := c.Request.Context()
 
-	err = h.Service.UpdateCatSalary(ctx, id, catRequest.Salary)
+	cat, err := h.Service.UpdateCatSalary(ctx, id, catRequest.Salary, etag.IfMatch(c))
 	if err != nil {
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server."})
+		_ = c.Error(err)
 		return
 	}
 
+	etag.Set(c, cat.Version)
+
 	response := UpdateCatSalaryResponse{
 		ID:        id,
 		NewSalary: catRequest.Salary,
@@ -162,15 +164,15 @@ func (h *Handler) DeleteCat(c *gin.Context) {
 	stringID := c.Param("id")
 	id, err := strconv.Atoi(stringID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "The requested resource does not exist."})
+		_ = c.Error(NotFoundErr)
 		return
 	}
 
 	ctx := c.Request.Context()
 
-	err = h.Service.DeleteCat(ctx, id)
+	err = h.Service.DeleteCat(ctx, id, etag.IfMatch(c))
 	if err != nil {
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server."})
+		_ = c.Error(err)
 		return
 	}
 
@@ -4,21 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"spy-cat-agency/internal/db"
 )
 
 type Repository struct {
-	conn *sql.DB
+	conn *db.DB
 }
 
-func NewRepository(conn *sql.DB) *Repository {
+func NewRepository(conn *db.DB) *Repository {
 	return &Repository{conn: conn}
 }
 
-func (r *Repository) GetAllCats() ([]Cat, error) {
-	query := `SELECT * FROM cats`
+func (r *Repository) GetAllCats(ctx context.Context) ([]Cat, error) {
+	query := `SELECT id, name, breed, years_of_experience, salary, created_at, version FROM cats`
 
-	rows, err := r.conn.Query(query)
+	rows, err := r.conn.Reader(ctx).QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -28,7 +28,7 @@ func (r *Repository) GetAllCats() ([]Cat, error) {
 
 	for rows.Next() {
 		var cat Cat
-		if err = rows.Scan(&cat.ID, &cat.Name, &cat.Breed, &cat.YearsOfExperience, &cat.Salary, &cat.CreatedAt); err != nil {
+		if err = rows.Scan(&cat.ID, &cat.Name, &cat.Breed, &cat.YearsOfExperience, &cat.Salary, &cat.CreatedAt, &cat.Version); err != nil {
 			return nil, err
 		}
 		cats = append(cats, cat)
@@ -37,10 +37,10 @@ func (r *Repository) GetAllCats() ([]Cat, error) {
 	return cats, nil
 }
 
-func (r *Repository) CreateCat(cat *Cat) (int, error) {
-	query := `INSERT INTO cats (name, years_of_experience, breed, salary) VALUES ($1, $2, $3, $4) RETURNING id`
+func (r *Repository) CreateCat(ctx context.Context, cat *Cat) (int, error) {
+	query := `INSERT INTO cats (name, years_of_experience, breed, salary) VALUES ($1, $2, $3, $4) RETURNING id, created_at, version`
 
-	err := r.conn.QueryRow(query, cat.Name, cat.YearsOfExperience, cat.Breed, cat.Salary).Scan(&cat.ID)
+	err := r.conn.QueryRowContext(ctx, query, cat.Name, cat.YearsOfExperience, cat.Breed, cat.Salary).Scan(&cat.ID, &cat.CreatedAt, &cat.Version)
 	if err != nil {
 		return 0, err
 	}
@@ -48,33 +48,35 @@ func (r *Repository) CreateCat(cat *Cat) (int, error) {
 	return cat.ID, nil
 }
 
-func (r *Repository) GetCatByID(id int) (*Cat, error) {
-	query := `SELECT * FROM cats WHERE id = $1`
+func (r *Repository) GetCatByID(ctx context.Context, id int) (*Cat, error) {
+	query := `SELECT id, name, breed, years_of_experience, salary, created_at, version FROM cats WHERE id = $1`
 
 	var cat Cat
-	err := r.conn.QueryRow(query, id).Scan(&cat.ID, &cat.Name, &cat.Breed, &cat.YearsOfExperience, &cat.Salary, &cat.CreatedAt)
+	err := r.conn.Reader(ctx).QueryRowContext(ctx, query, id).Scan(&cat.ID, &cat.Name, &cat.Breed, &cat.YearsOfExperience, &cat.Salary, &cat.CreatedAt, &cat.Version)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
-		log.Println(err)
 		return nil, err
 	}
 
 	return &cat, nil
 }
 
-func (r *Repository) UpdateCat(ctx context.Context, cat *Cat) error {
-	query := `UPDATE cats SET name = $1, years_of_experience = $2, breed = $3, salary = $4 WHERE id = $5`
+func (r *Repository) UpdateCat(ctx context.Context, cat *Cat, version *int) error {
+	query := `
+		UPDATE cats
+		SET name = $1, years_of_experience = $2, breed = $3, salary = $4, version = version + 1
+		WHERE id = $5 AND ($6::integer IS NULL OR version = $6)
+		RETURNING version`
 
-	_, err := r.conn.ExecContext(ctx, query, cat.Name, cat.YearsOfExperience, cat.Breed, cat.Salary, cat.ID)
-	return err
+	return r.conn.QueryRowContext(ctx, query, cat.Name, cat.YearsOfExperience, cat.Breed, cat.Salary, cat.ID, version).Scan(&cat.Version)
 }
 
-func (r *Repository) DeleteCat(ctx context.Context, id int) error {
-	query := `DELETE FROM cats WHERE id = $1`
+func (r *Repository) DeleteCat(ctx context.Context, id int, version *int) error {
+	query := `DELETE FROM cats WHERE id = $1 AND ($2::integer IS NULL OR version = $2)`
 
-	res, err := r.conn.ExecContext(ctx, query, id)
+	res, err := r.conn.ExecContext(ctx, query, id, version)
 	if err != nil {
 		return err
 	}
@@ -90,3 +92,7 @@ func (r *Repository) DeleteCat(ctx context.Context, id int) error {
 
 	return nil
 }
+
+func (r *Repository) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.conn.InTx(ctx, fn)
+}
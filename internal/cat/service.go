@@ -3,33 +3,45 @@ package cat
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
-	"io"
-	"net/http"
-	"strings"
+	"spy-cat-agency/internal/audit"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/db"
+	"spy-cat-agency/internal/outbox"
+	"spy-cat-agency/internal/tracing"
 )
 
 var (
-	NotFoundErr   = errors.New("not found")
-	WrongBreedErr = errors.New("the specified breed is not recognized")
+	NotFoundErr           = errors.New("cat not found")
+	WrongBreedErr         = errors.New("the specified breed is not recognized")
+	PreconditionFailedErr = errors.New("cat has been modified since it was read")
 )
 
-type Breed struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-}
-
 type Service struct {
-	repo *Repository
+	repo    *Repository
+	catalog *BreedCatalog
+	audit   *audit.Service
+	outbox  *outbox.Service
 }
 
-func NewService(repo *Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo *Repository, catalog *BreedCatalog, audit *audit.Service, outbox *outbox.Service) *Service {
+	return &Service{
+		repo:    repo,
+		catalog: catalog,
+		audit:   audit,
+		outbox:  outbox,
+	}
 }
 
-func (s *Service) ListCats() ([]Cat, error) {
-	cats, err := s.repo.GetAllCats()
+func (s *Service) ListCats(ctx context.Context) ([]Cat, error) {
+	ctx, span := tracing.Start(ctx, "cat.Service.ListCats")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.CatsRead); err != nil {
+		return nil, err
+	}
+
+	cats, err := s.repo.GetAllCats(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -37,8 +49,15 @@ func (s *Service) ListCats() ([]Cat, error) {
 	return cats, nil
 }
 
-func (s *Service) CreateCat(name, breed string, yearsOfExperience int, salary float64) (int, error) {
-	isValid, err := validateBreed(breed)
+func (s *Service) CreateCat(ctx context.Context, name, breed string, yearsOfExperience int, salary float64) (int, error) {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "cat.Service.CreateCat")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.CatsWrite); err != nil {
+		return 0, err
+	}
+
+	isValid, err := s.catalog.Contains(ctx, breed)
 	if err != nil {
 		return 0, WrongBreedErr
 	}
@@ -54,11 +73,36 @@ func (s *Service) CreateCat(name, breed string, yearsOfExperience int, salary fl
 		Salary:            salary,
 	}
 
-	return s.repo.CreateCat(cat)
+	err = s.repo.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.repo.CreateCat(ctx, cat); err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, audit.EntityCat, cat.ID, audit.ActionCreate, nil, cat); err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, outbox.AggregateCat, cat.ID, outbox.CatHired, HiredEvent{
+			CatID:             cat.ID,
+			Name:              cat.Name,
+			Breed:             cat.Breed,
+			YearsOfExperience: cat.YearsOfExperience,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return cat.ID, nil
 }
 
-func (s *Service) GetCat(id int) (*Cat, error) {
-	cat, err := s.repo.GetCatByID(id)
+func (s *Service) GetCat(ctx context.Context, id int) (*Cat, error) {
+	ctx, span := tracing.Start(ctx, "cat.Service.GetCat")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.CatsRead); err != nil {
+		return nil, err
+	}
+
+	cat, err := s.repo.GetCatByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -68,53 +112,97 @@ func (s *Service) GetCat(id int) (*Cat, error) {
 	return cat, nil
 }
 
-func (s *Service) UpdateCatSalary(ctx context.Context, id int, salary float64) error {
-	cat, err := s.repo.GetCatByID(id)
-	if err != nil {
-		return err
+func (s *Service) UpdateCatSalary(ctx context.Context, id int, salary float64, version *int) (*Cat, error) {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "cat.Service.UpdateCatSalary")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.CatsWrite); err != nil {
+		return nil, err
 	}
 
-	cat.Salary = salary
+	var cat Cat
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetCatByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if before == nil {
+			return NotFoundErr
+		}
+		if version != nil && *version != before.Version {
+			return PreconditionFailedErr
+		}
+
+		cat = *before
+		cat.Salary = salary
 
-	return s.repo.UpdateCat(ctx, cat)
-}
+		err = s.repo.UpdateCat(ctx, &cat, version)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFoundOrModified(version)
+			}
+			return err
+		}
 
-func (s *Service) DeleteCat(ctx context.Context, id int) error {
-	err := s.repo.DeleteCat(ctx, id)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return NotFoundErr
+		if err := s.audit.Record(ctx, audit.EntityCat, id, audit.ActionUpdate, before, &cat); err != nil {
+			return err
 		}
-		return err
+
+		if cat.Salary == before.Salary {
+			return nil
+		}
+		return s.outbox.Add(ctx, outbox.AggregateCat, id, outbox.CatSalaryChanged, SalaryChangedEvent{
+			CatID:     id,
+			OldSalary: before.Salary,
+			NewSalary: cat.Salary,
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &cat, nil
 }
 
-func validateBreed(breed string) (bool, error) {
-	resp, err := http.Get("https://api.thecatapi.com/v1/breeds")
-	if err != nil {
-		return false, err
-	}
-	defer resp.Body.Close()
+func (s *Service) DeleteCat(ctx context.Context, id int, version *int) error {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "cat.Service.DeleteCat")
+	defer span.End()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return false, err
+	if _, err := auth.Authorize(ctx, auth.CatsWrite); err != nil {
+		return err
 	}
 
-	var breeds []Breed
-	err = json.Unmarshal(body, &breeds)
-	if err != nil {
-		return false, err
-	}
+	return s.repo.InTx(ctx, func(ctx context.Context) error {
+		cat, err := s.repo.GetCatByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if cat == nil {
+			return NotFoundErr
+		}
+		if version != nil && *version != cat.Version {
+			return PreconditionFailedErr
+		}
 
-	for _, b := range breeds {
-		if strings.EqualFold(b.Name, breed) {
-			return true, nil
+		err = s.repo.DeleteCat(ctx, id, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return notFoundOrModified(version)
+			}
+			return err
 		}
-	}
 
-	return false, nil
+		if err := s.audit.Record(ctx, audit.EntityCat, id, audit.ActionDelete, cat, nil); err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, outbox.AggregateCat, id, outbox.CatDeleted, DeletedEvent{CatID: id})
+	})
+}
+
+func notFoundOrModified(version *int) error {
+	if version != nil {
+		return PreconditionFailedErr
+	}
+	return NotFoundErr
 }
@@ -0,0 +1,81 @@
+package change
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Operation string
+
+const (
+	OperationInsert Operation = "insert"
+	OperationUpdate Operation = "update"
+	OperationDelete Operation = "delete"
+)
+
+var (
+	InvalidCursorErr = errors.New("invalid change feed cursor")
+	CursorExpiredErr = errors.New("cursor is older than the retained changes, resync and start from a new cursor")
+)
+
+type Change struct {
+	ID         int64
+	TxID       uint64
+	EntityType string
+	EntityID   int
+	Operation  Operation
+	Version    int
+	Data       json.RawMessage
+	ChangedAt  time.Time
+}
+
+// Cursor is a position in the feed: changes are ordered by the transaction that made them, then by id.
+type Cursor struct {
+	TxID uint64
+	ID   int64
+}
+
+func (c Change) Cursor() Cursor {
+	return Cursor{TxID: c.TxID, ID: c.ID}
+}
+
+func (c Cursor) Before(o Cursor) bool {
+	return c.TxID < o.TxID || c.TxID == o.TxID && c.ID < o.ID
+}
+
+func (c Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", c.TxID, c.ID)))
+}
+
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, InvalidCursorErr
+	}
+
+	tx, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return Cursor{}, InvalidCursorErr
+	}
+
+	var c Cursor
+	if c.TxID, err = strconv.ParseUint(tx, 10, 64); err != nil {
+		return Cursor{}, InvalidCursorErr
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil || c.ID < 0 {
+		return Cursor{}, InvalidCursorErr
+	}
+
+	return c, nil
+}
+
+type Page struct {
+	Changes []Change
+	Cursor  Cursor
+	HasMore bool
+}
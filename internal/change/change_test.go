@@ -0,0 +1,75 @@
+package change
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{},
+		{TxID: 748, ID: 12},
+		{TxID: 748},
+		{TxID: math.MaxUint64, ID: math.MaxInt64},
+	}
+
+	for _, c := range tests {
+		s := c.String()
+		got, err := ParseCursor(s)
+		if err != nil {
+			t.Errorf("ParseCursor(%q) error = %v", s, err)
+			continue
+		}
+		if got != c {
+			t.Errorf("ParseCursor(%q) = %+v, want %+v", s, got, c)
+		}
+	}
+}
+
+func TestParseCursorInvalid(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not a cursor!"},
+		{"padded", base64.URLEncoding.EncodeToString([]byte("748.1"))},
+		{"no separator", encode("74812")},
+		{"empty parts", encode(".")},
+		{"negative transaction", encode("-1.12")},
+		{"negative id", encode("748.-1")},
+		{"transaction too large", encode("18446744073709551616.12")},
+		{"trailing data", encode("748.12.3")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := ParseCursor(tt.cursor); !errors.Is(err, InvalidCursorErr) {
+				t.Errorf("ParseCursor(%q) = %+v, %v; want %v", tt.cursor, c, err, InvalidCursorErr)
+			}
+		})
+	}
+}
+
+func TestCursorBefore(t *testing.T) {
+	tests := []struct {
+		a, b Cursor
+		want bool
+	}{
+		{Cursor{TxID: 1, ID: 9}, Cursor{TxID: 2, ID: 1}, true},
+		{Cursor{TxID: 2, ID: 1}, Cursor{TxID: 2, ID: 2}, true},
+		{Cursor{TxID: 2, ID: 2}, Cursor{TxID: 2, ID: 2}, false},
+		{Cursor{TxID: 3, ID: 1}, Cursor{TxID: 2, ID: 9}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Before(tt.b); got != tt.want {
+			t.Errorf("%+v.Before(%+v) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package change
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+type ListChangesRequest struct {
+	Since string `form:"since"`
+	Limit int    `form:"limit"`
+}
+
+type ChangeResponse struct {
+	EntityType string          `json:"entity_type"`
+	EntityID   int             `json:"entity_id"`
+	Operation  Operation       `json:"operation"`
+	Version    int             `json:"version"`
+	Data       json.RawMessage `json:"data"`
+	ChangedAt  time.Time       `json:"changed_at"`
+}
+
+type ListChangesResponse struct {
+	Changes []ChangeResponse `json:"changes"`
+	Cursor  string           `json:"cursor"`
+	HasMore bool             `json:"has_more"`
+}
+
+type Handler struct {
+	Service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{Service: service}
+}
+
+func (h *Handler) ListChanges(c *gin.Context) {
+	var request ListChangesRequest
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	var since *Cursor
+	if request.Since != "" {
+		cursor, err := ParseCursor(request.Since)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		since = &cursor
+	}
+
+	page, err := h.Service.ListChanges(c.Request.Context(), since, request.Limit)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := ListChangesResponse{
+		Changes: make([]ChangeResponse, 0, len(page.Changes)),
+		Cursor:  page.Cursor.String(),
+		HasMore: page.HasMore,
+	}
+	for _, ch := range page.Changes {
+		response.Changes = append(response.Changes, ChangeResponse{
+			EntityType: ch.EntityType,
+			EntityID:   ch.EntityID,
+			Operation:  ch.Operation,
+			Version:    ch.Version,
+			Data:       ch.Data,
+			ChangedAt:  ch.ChangedAt,
+		})
+	}
+
+	c.JSON(200, response)
+}
@@ -0,0 +1,110 @@
+package change
+
+import (
+	"context"
+	"spy-cat-agency/internal/db"
+	"strconv"
+	"time"
+)
+
+type Repository struct {
+	conn *db.DB
+}
+
+func NewRepository(conn *db.DB) *Repository {
+	return &Repository{conn: conn}
+}
+
+// GetChanges returns up to limit changes after the cursor. Changes of transactions that may still be running,
+// or that started after one that may, are held back until they are all finished: ids and transaction ids are
+// handed out before commit, so serving them early could let a later page skip a change that commits late.
+func (r *Repository) GetChanges(ctx context.Context, after Cursor, limit int) ([]Change, error) {
+	query := `
+		SELECT id, tx_id::text, entity_type, entity_id, operation, version, data, changed_at
+		FROM changes
+		WHERE (tx_id, id) > ($1::xid8, $2::bigint)
+			AND tx_id < pg_snapshot_xmin(pg_current_snapshot())
+		ORDER BY tx_id, id
+		LIMIT $3`
+
+	rows, err := r.conn.Reader(ctx).QueryContext(ctx, query, strconv.FormatUint(after.TxID, 10), after.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]Change, 0)
+
+	for rows.Next() {
+		var c Change
+		var txID string
+		var data []byte
+		err = rows.Scan(&c.ID, &txID, &c.EntityType, &c.EntityID, &c.Operation, &c.Version, &data, &c.ChangedAt)
+		if err != nil {
+			return nil, err
+		}
+		if c.TxID, err = strconv.ParseUint(txID, 10, 64); err != nil {
+			return nil, err
+		}
+		c.Data = data
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+// Head returns the position that every finished transaction is behind of.
+func (r *Repository) Head(ctx context.Context) (Cursor, error) {
+	query := `SELECT pg_snapshot_xmin(pg_current_snapshot())::text`
+
+	var txID string
+	if err := r.conn.Reader(ctx).QueryRowContext(ctx, query).Scan(&txID); err != nil {
+		return Cursor{}, err
+	}
+
+	tx, err := strconv.ParseUint(txID, 10, 64)
+	if err != nil {
+		return Cursor{}, err
+	}
+
+	return Cursor{TxID: tx}, nil
+}
+
+// Horizon returns the position of the newest change deleted by the cleanup. It reads the primary, which is
+// never behind the replica the changes were read from.
+func (r *Repository) Horizon(ctx context.Context) (Cursor, error) {
+	query := `SELECT tx_id::text, id FROM changes_horizon`
+
+	var c Cursor
+	var txID string
+	if err := r.conn.QueryRowContext(ctx, query).Scan(&txID, &c.ID); err != nil {
+		return Cursor{}, err
+	}
+
+	tx, err := strconv.ParseUint(txID, 10, 64)
+	if err != nil {
+		return Cursor{}, err
+	}
+	c.TxID = tx
+
+	return c, nil
+}
+
+// DeleteExpired removes the changes made before cutoff and moves the horizon past the newest of them.
+func (r *Repository) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `
+		WITH deleted AS (
+			DELETE FROM changes WHERE changed_at < $1 RETURNING tx_id, id
+		), newest AS (
+			SELECT tx_id, id FROM deleted ORDER BY tx_id DESC, id DESC LIMIT 1
+		), horizon AS (
+			UPDATE changes_horizon h SET tx_id = newest.tx_id, id = newest.id
+			FROM newest
+			WHERE (newest.tx_id, newest.id) > (h.tx_id, h.id)
+		)
+		SELECT COUNT(*) FROM deleted`
+
+	var n int64
+	err := r.conn.QueryRowContext(ctx, query, cutoff).Scan(&n)
+	return n, err
+}
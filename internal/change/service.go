@@ -0,0 +1,96 @@
+package change
+
+import (
+	"context"
+	"log/slog"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/tracing"
+	"time"
+)
+
+const defaultLimit = 100
+
+// store is the part of Repository the service reads the feed through.
+type store interface {
+	GetChanges(ctx context.Context, after Cursor, limit int) ([]Change, error)
+	Head(ctx context.Context) (Cursor, error)
+	Horizon(ctx context.Context) (Cursor, error)
+	DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type Service struct {
+	repo store
+	cfg  config.ChangesConfig
+}
+
+func NewService(repo *Repository, cfg config.ChangesConfig) *Service {
+	return &Service{repo: repo, cfg: cfg}
+}
+
+// ListChanges returns the changes after since. Without since it returns no changes, only the cursor to start
+// from after loading the current cats and missions. The cursor of an empty page is the one passed in.
+func (s *Service) ListChanges(ctx context.Context, since *Cursor, limit int) (*Page, error) {
+	ctx, span := tracing.Start(ctx, "change.Service.ListChanges")
+	defer span.End()
+
+	for _, perm := range []auth.Permission{auth.CatsRead, auth.MissionsRead} {
+		if _, err := auth.Authorize(ctx, perm); err != nil {
+			return nil, err
+		}
+	}
+
+	if since == nil {
+		head, err := s.repo.Head(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &Page{Changes: make([]Change, 0), Cursor: head}, nil
+	}
+
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	changes, err := s.repo.GetChanges(ctx, *since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checked after reading, so a cleanup that ran in between is noticed too.
+	horizon, err := s.repo.Horizon(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if since.Before(horizon) {
+		return nil, CursorExpiredErr
+	}
+
+	page := &Page{Changes: changes, Cursor: *since, HasMore: len(changes) == limit}
+	if len(changes) > 0 {
+		page.Cursor = changes[len(changes)-1].Cursor()
+	}
+
+	return page, nil
+}
+
+func (s *Service) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.repo.DeleteExpired(ctx, time.Now().Add(-s.cfg.Retention))
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to delete expired changes", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "Deleted expired changes", "count", n)
+			}
+		}
+	}
+}
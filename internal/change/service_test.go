@@ -0,0 +1,108 @@
+package change
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"spy-cat-agency/internal/auth"
+	"testing"
+	"time"
+)
+
+// fakeStore serves changes the way the feed orders them, after the horizon left behind by the cleanup.
+type fakeStore struct {
+	changes []Change
+	head    Cursor
+	horizon Cursor
+}
+
+func (s *fakeStore) GetChanges(_ context.Context, after Cursor, limit int) ([]Change, error) {
+	changes := make([]Change, 0)
+	for _, c := range s.changes {
+		if after.Before(c.Cursor()) && len(changes) < limit {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+func (s *fakeStore) Head(context.Context) (Cursor, error) {
+	return s.head, nil
+}
+
+func (s *fakeStore) Horizon(context.Context) (Cursor, error) {
+	return s.horizon, nil
+}
+
+func (s *fakeStore) DeleteExpired(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func handlerContext() context.Context {
+	return auth.NewContext(context.Background(), &auth.Principal{Subject: "user:1", Role: auth.RoleHandler})
+}
+
+func TestListChanges(t *testing.T) {
+	store := &fakeStore{
+		changes: []Change{
+			{ID: 4, TxID: 10, EntityType: "cat"},
+			{ID: 5, TxID: 10, EntityType: "mission"},
+			{ID: 3, TxID: 12, EntityType: "cat"},
+			{ID: 6, TxID: 13, EntityType: "mission"},
+		},
+		head:    Cursor{TxID: 14},
+		horizon: Cursor{TxID: 9, ID: 2},
+	}
+
+	tests := []struct {
+		name    string
+		since   *Cursor
+		limit   int
+		want    []int64
+		cursor  Cursor
+		hasMore bool
+		err     error
+	}{
+		{name: "without a cursor", cursor: Cursor{TxID: 14}},
+		{name: "from the horizon", since: &Cursor{TxID: 9, ID: 2}, want: []int64{4, 5, 3, 6}, cursor: Cursor{TxID: 13, ID: 6}},
+		{name: "one page", since: &Cursor{TxID: 10, ID: 4}, limit: 2, want: []int64{5, 3}, cursor: Cursor{TxID: 12, ID: 3}, hasMore: true},
+		{name: "up to date", since: &Cursor{TxID: 13, ID: 6}, want: []int64{}, cursor: Cursor{TxID: 13, ID: 6}},
+		{name: "older than the horizon", since: &Cursor{TxID: 9, ID: 1}, err: CursorExpiredErr},
+		{name: "before any retained transaction", since: &Cursor{TxID: 3, ID: 7}, err: CursorExpiredErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{repo: store}
+
+			page, err := s.ListChanges(handlerContext(), tt.since, tt.limit)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ListChanges() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			ids := make([]int64, 0)
+			for _, c := range page.Changes {
+				ids = append(ids, c.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("changes %v, want %v", ids, tt.want)
+			}
+			if page.Cursor != tt.cursor || page.HasMore != tt.hasMore {
+				t.Errorf("cursor %+v, has more %t; want %+v, %t", page.Cursor, page.HasMore, tt.cursor, tt.hasMore)
+			}
+		})
+	}
+}
+
+func TestListChangesForbidden(t *testing.T) {
+	catID := 3
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "user:2", Role: auth.RoleFieldAgent, CatID: &catID})
+
+	s := &Service{repo: &fakeStore{}}
+	if _, err := s.ListChanges(ctx, nil, 0); !errors.Is(err, auth.ForbiddenErr) {
+		t.Errorf("field agent: error = %v, want %v", err, auth.ForbiddenErr)
+	}
+}
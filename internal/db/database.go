@@ -6,40 +6,138 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	"log"
+	"github.com/lib/pq"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"spy-cat-agency/config"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
-func Connect(user, password, host, name string, port int) (*sql.DB, error) {
-	databaseURL := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable", user, password, host, port, name)
+var (
+	DirtySchemaErr    = errors.New("database schema is dirty")
+	OutdatedSchemaErr = errors.New("database schema is outdated")
+)
+
+const (
+	connectBackoffMin = 500 * time.Millisecond
+	connectBackoffMax = 10 * time.Second
+	pingTimeout       = 5 * time.Second
+)
+
+const (
+	listenerBackoffMin = time.Second
+	listenerBackoffMax = time.Minute
+)
 
-	conn, err := sql.Open("postgres", databaseURL)
+func Connect(ctx context.Context, cfg config.DatabaseConfig) (*DB, error) {
+	conn, err := sql.Open("postgres", dsn(cfg))
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	configure := func(conn *sql.DB) {
+		conn.SetMaxOpenConns(cfg.MaxOpenConns)
+		conn.SetMaxIdleConns(cfg.MaxIdleConns)
+		conn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		conn.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	configure(conn)
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
 	defer cancel()
 
-	err = conn.PingContext(ctx)
-	if err != nil {
+	backoff := connectBackoffMin
+	for attempt := 1; ; attempt++ {
+		pingCtx, pingCancel := context.WithTimeout(ctx, pingTimeout)
+		err = conn.PingContext(pingCtx)
+		pingCancel()
+		if err == nil {
+			break
+		}
+
+		if deadline, _ := ctx.Deadline(); ctx.Err() != nil || time.Until(deadline) < backoff {
+			_ = conn.Close()
+			return nil, fmt.Errorf("connecting to database after %d attempts: %w", attempt, err)
+		}
+
+		slog.WarnContext(ctx, "Database is not reachable, retrying",
+			"attempt", attempt, "retry_in", backoff, "error", err)
+
+		time.Sleep(backoff)
+
+		backoff = min(backoff*2, connectBackoffMax)
+	}
+
+	d := &DB{DB: conn}
+	if err = d.openReplicas(ctx, cfg, configure); err != nil {
+		_ = d.Close()
 		return nil, err
 	}
 
-	return conn, nil
+	return d, nil
 }
 
-func Migrate(conn *sql.DB, path string) error {
+func dsn(cfg config.DatabaseConfig) string {
+	query := url.Values{}
+	query.Set("sslmode", cfg.SSLMode)
+	if cfg.SSLRootCert != "" {
+		query.Set("sslrootcert", cfg.SSLRootCert)
+	}
+	if cfg.ApplicationName != "" {
+		query.Set("application_name", cfg.ApplicationName)
+	}
+	if cfg.StatementTimeout > 0 {
+		query.Set("statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10))
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:     "/" + cfg.DBName,
+		RawQuery: query.Encode(),
+	}
+
+	return u.String()
+}
+
+// NewListener opens a dedicated connection to the primary for LISTEN. It reconnects by itself when the
+// connection drops; notifications sent in the meantime are lost.
+func NewListener(cfg config.DatabaseConfig) *pq.Listener {
+	return pq.NewListener(dsn(cfg), listenerBackoffMin, listenerBackoffMax, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("Database listener connection failed", "error", err)
+		}
+	})
+}
+
+func NewMigrator(conn *sql.DB, path string) (*migrate.Migrate, error) {
 	driver, err := postgres.WithInstance(conn, &postgres.Config{})
 	if err != nil {
-		return err
+		return nil, err
 	}
+
 	m, err := migrate.NewWithDatabaseInstance("file://"+path, "postgres", driver)
+	if err != nil {
+		return nil, err
+	}
+	m.Log = migrationLogger{}
+
+	return m, nil
+}
+
+func Migrate(conn *sql.DB, path string) error {
+	m, err := NewMigrator(conn, path)
 	if err != nil {
 		return err
 	}
@@ -47,10 +145,77 @@ func Migrate(conn *sql.DB, path string) error {
 	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return err
 	} else if errors.Is(err, migrate.ErrNoChange) {
-		log.Println("No new migrations to apply.")
+		slog.Info("No new migrations to apply")
 	} else {
-		log.Println("Migrations applied successfully!")
+		slog.Info("Migrations applied successfully")
 	}
 
 	return nil
 }
+
+func LatestMigration(path string) (uint, error) {
+	src, err := source.Open("file://" + path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+func SchemaVersion(ctx context.Context, conn *DB) (uint, bool, error) {
+	query := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+
+	var version uint
+	var dirty bool
+	err := conn.QueryRowContext(ctx, query).Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return version, dirty, nil
+}
+
+func CheckSchema(ctx context.Context, conn *DB, latest uint) error {
+	version, dirty, err := SchemaVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		return fmt.Errorf("%w: version %d", DirtySchemaErr, version)
+	}
+
+	if version < latest {
+		return fmt.Errorf("%w: version %d, latest migration is %d", OutdatedSchemaErr, version, latest)
+	}
+
+	return nil
+}
+
+type migrationLogger struct{}
+
+func (migrationLogger) Printf(format string, v ...any) {
+	slog.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (migrationLogger) Verbose() bool {
+	return false
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+	"spy-cat-agency/internal/requestid"
+	"spy-cat-agency/internal/tracing"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DB wraps *sql.DB so that every statement is traced and tagged with the request ID carried by its
+// context, which lets slow or failing queries in the Postgres logs be traced back to their request.
+type DB struct {
+	*sql.DB
+
+	replicas []*replica
+	maxLag   time.Duration
+	next     atomic.Uint64
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+type Tx struct {
+	*sql.Tx
+
+	savepoints int
+}
+
+type txKey struct{}
+
+// InTx runs fn in a transaction on the primary. Statements issued through d with the context passed to fn
+// join the transaction, so repositories take part without being handed it; nested calls reuse the outer one.
+func (d *DB) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if txFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	ctx = WithPrimary(ctx)
+
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InSavepoint runs fn under a savepoint of the transaction in ctx. When fn fails only its own statements are
+// undone, and the transaction stays usable instead of being aborted. Outside a transaction it is InTx.
+func (d *DB) InSavepoint(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx := txFromContext(ctx)
+	if tx == nil {
+		return d.InTx(ctx, fn)
+	}
+
+	tx.savepoints++
+	name := fmt.Sprintf("sp_%d", tx.savepoints)
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	if err := fn(ctx); err != nil {
+		if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+func txFromContext(ctx context.Context) *Tx {
+	tx, _ := ctx.Value(txKey{}).(*Tx)
+	return tx
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+
+	ctx, span := startSpan(ctx, query)
+	res, err := d.DB.ExecContext(ctx, annotate(ctx, query), args...)
+	endSpan(span, err)
+	return res, err
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx.QueryContext(ctx, query, args...)
+	}
+
+	ctx, span := startSpan(ctx, query)
+	rows, err := d.DB.QueryContext(ctx, annotate(ctx, query), args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx.QueryRowContext(ctx, query, args...)
+	}
+
+	ctx, span := startSpan(ctx, query)
+	row := d.DB.QueryRowContext(ctx, annotate(ctx, query), args...)
+	endSpan(span, row.Err())
+	return row
+}
+
+func (d *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := d.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{Tx: tx}, nil
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startSpan(ctx, query)
+	res, err := t.Tx.ExecContext(ctx, annotate(ctx, query), args...)
+	endSpan(span, err)
+	return res, err
+}
+
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startSpan(ctx, query)
+	rows, err := t.Tx.QueryContext(ctx, annotate(ctx, query), args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startSpan(ctx, query)
+	row := t.Tx.QueryRowContext(ctx, annotate(ctx, query), args...)
+	endSpan(span, row.Err())
+	return row
+}
+
+func annotate(ctx context.Context, query string) string {
+	id := requestid.FromContext(ctx)
+	if id == "" {
+		return query
+	}
+
+	return query + " /*request_id='" + id + "'*/"
+}
+
+func startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	text := strings.Join(strings.Fields(query), " ")
+	operation, _, _ := strings.Cut(text, " ")
+	operation = strings.ToUpper(operation)
+
+	return tracing.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(text),
+		),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
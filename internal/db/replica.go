@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"spy-cat-agency/config"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+type primaryKey struct{}
+
+type replica struct {
+	name    string
+	db      *DB
+	healthy atomic.Bool
+}
+
+// WithPrimary pins every query made with the returned context to the primary, giving read-your-writes
+// consistency to reads that would otherwise be routed to a possibly lagging replica.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func primaryRequired(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryKey{}).(bool)
+	return pinned
+}
+
+// Reader returns a healthy replica for read-only queries, or the primary when there is none or ctx was
+// created by WithPrimary.
+func (d *DB) Reader(ctx context.Context) *DB {
+	if len(d.replicas) == 0 || primaryRequired(ctx) {
+		return d
+	}
+
+	start := d.next.Add(1)
+	for i := range uint64(len(d.replicas)) {
+		r := d.replicas[(start+i)%uint64(len(d.replicas))]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+
+	return d
+}
+
+func (d *DB) ReplicasHealthy(context.Context) error {
+	var errs []error
+	for _, r := range d.replicas {
+		if !r.healthy.Load() {
+			errs = append(errs, fmt.Errorf("replica %s is unavailable or lagging", r.name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the replica health checks and closes the primary and replica connection pools.
+func (d *DB) Close() error {
+	if d.done != nil {
+		close(d.done)
+		<-d.stopped
+	}
+
+	var errs []error
+	for _, r := range d.replicas {
+		errs = append(errs, r.db.DB.Close())
+	}
+	errs = append(errs, d.DB.Close())
+
+	return errors.Join(errs...)
+}
+
+func (d *DB) openReplicas(ctx context.Context, cfg config.DatabaseConfig, configure func(*sql.DB)) error {
+	for i, dsn := range cfg.Replicas {
+		conn, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return fmt.Errorf("replica %d: %w", i, err)
+		}
+		configure(conn)
+
+		d.replicas = append(d.replicas, &replica{name: replicaName(i, dsn), db: &DB{DB: conn}})
+	}
+
+	if len(d.replicas) == 0 {
+		return nil
+	}
+
+	d.maxLag = cfg.ReplicaMaxLag
+	d.checkReplicas(ctx)
+
+	d.done = make(chan struct{})
+	d.stopped = make(chan struct{})
+	go d.monitorReplicas(cfg.ReplicaCheckInterval)
+
+	return nil
+}
+
+func (d *DB) monitorReplicas(interval time.Duration) {
+	defer close(d.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			d.checkReplicas(context.Background())
+		}
+	}
+}
+
+func (d *DB) checkReplicas(ctx context.Context) {
+	for _, r := range d.replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err := r.check(checkCtx, d.maxLag)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) == healthy {
+			continue
+		}
+
+		if healthy {
+			slog.Info("Database replica is available", "replica", r.name)
+		} else {
+			slog.Warn("Database replica is unavailable or lagging, reads fall back to other replicas or the primary",
+				"replica", r.name, "error", err)
+		}
+	}
+}
+
+// check pings the replica and, when maxLag is set, fails if it is further behind the primary. A replica that
+// has replayed all the WAL it received counts as caught up, even if the primary has written nothing for a while.
+func (r *replica) check(ctx context.Context, maxLag time.Duration) error {
+	if err := r.db.DB.PingContext(ctx); err != nil {
+		return err
+	}
+	if maxLag <= 0 {
+		return nil
+	}
+
+	query := `
+		SELECT CASE
+			WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END::float8`
+
+	var seconds float64
+	if err := r.db.DB.QueryRowContext(ctx, query).Scan(&seconds); err != nil {
+		return err
+	}
+
+	if lag := time.Duration(seconds * float64(time.Second)); lag > maxLag {
+		return fmt.Errorf("replica is %s behind the primary, more than the allowed %s", lag.Round(time.Millisecond), maxLag)
+	}
+	return nil
+}
+
+func replicaName(i int, dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Host == "" {
+		return strconv.Itoa(i)
+	}
+	return u.Host
+}
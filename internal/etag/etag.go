@@ -0,0 +1,75 @@
+package etag
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func Format(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func Set(c *gin.Context, version int) {
+	c.Header("ETag", Format(version))
+}
+
+// IfMatch returns the version the If-Match header requires, or nil when the request is unconditional.
+// Tags that can never match a current version (weak or unknown ones) are reported as version 0, so the
+// precondition fails as RFC 9110 requires. Of a list only the first strong tag counts: the request may fail
+// where another listed tag would have matched, but never goes through unchecked.
+func IfMatch(c *gin.Context) *int {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return nil
+	}
+
+	version := 0
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil
+		}
+		if v, ok := parse(tag); ok {
+			version = v
+			break
+		}
+	}
+
+	return &version
+}
+
+// NotModified answers a conditional GET: when If-None-Match lists the current version it writes
+// 304 Not Modified and returns true. Either way the response carries the current ETag.
+func NotModified(c *gin.Context, version int) bool {
+	Set(c, version)
+
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if v, ok := parse(tag); tag == "*" || ok && v == version {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+func parse(tag string) (int, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+
+	v, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+
+	return v, true
+}
@@ -0,0 +1,92 @@
+package etag
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newContext(header, value string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/cats/1", nil)
+	if value != "" {
+		c.Request.Header.Set(header, value)
+	}
+	return c, w
+}
+
+func TestIfMatch(t *testing.T) {
+	version := func(v int) *int { return &v }
+
+	tests := []struct {
+		name   string
+		header string
+		want   *int
+	}{
+		{"absent", "", nil},
+		{"strong", `"4"`, version(4)},
+		{"any", "*", nil},
+		{"weak", `W/"4"`, version(0)},
+		{"unquoted", "4", version(0)},
+		{"not a number", `"abc"`, version(0)},
+		{"not a version", `"0"`, version(0)},
+		{"list", `"4", "5"`, version(4)},
+		{"list after a weak tag", `W/"4", "5"`, version(5)},
+		{"list with any", `"abc", *`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newContext("If-Match", tt.header)
+
+			got := IfMatch(c)
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil:
+				t.Errorf("IfMatch() = %v, want %v", got, tt.want)
+			case *got != *tt.want:
+				t.Errorf("IfMatch() = %d, want %d", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"absent", "", false},
+		{"current", `"4"`, true},
+		{"stale", `"3"`, false},
+		{"weak current", `W/"4"`, true},
+		{"any", "*", true},
+		{"list with current", `"3", W/"4"`, true},
+		{"list without current", `"2", "3"`, false},
+		{"unparseable", "4", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newContext("If-None-Match", tt.header)
+
+			if got := NotModified(c, 4); got != tt.want {
+				t.Errorf("NotModified() = %t, want %t", got, tt.want)
+			}
+			if got := w.Header().Get("ETag"); got != `"4"` {
+				t.Errorf("ETag = %q, want %q", got, `"4"`)
+			}
+			status := http.StatusOK
+			if tt.want {
+				status = http.StatusNotModified
+			}
+			if got := c.Writer.Status(); got != status {
+				t.Errorf("status = %d, want %d", got, status)
+			}
+		})
+	}
+}
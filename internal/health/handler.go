@@ -0,0 +1,105 @@
+package health
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Latency  string `json:"latency"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type Handler struct {
+	checks       []check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewHandler(timeout time.Duration) *Handler {
+	return &Handler{timeout: timeout}
+}
+
+// AddCheck registers a dependency check. A failing critical check makes the service not ready,
+// a failing non-critical one is only reported.
+func (h *Handler) AddCheck(name string, critical bool, fn CheckFunc) {
+	h.checks = append(h.checks, check{name: name, critical: critical, fn: fn})
+}
+
+func (h *Handler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+func (h *Handler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+func (h *Handler) Readiness(c *gin.Context) {
+	if h.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, ReadinessResponse{Status: "shutting_down", Checks: map[string]CheckResult{}})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	defer cancel()
+
+	results := make([]CheckResult, len(h.checks))
+
+	var wg sync.WaitGroup
+	for i, chk := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := chk.fn(ctx)
+
+			result := CheckResult{
+				Status:   "up",
+				Critical: chk.critical,
+				Latency:  time.Since(start).String(),
+			}
+			if err != nil {
+				result.Status = "down"
+				result.Error = err.Error()
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	response := ReadinessResponse{
+		Status: "ready",
+		Checks: make(map[string]CheckResult, len(h.checks)),
+	}
+	status := http.StatusOK
+
+	for i, chk := range h.checks {
+		response.Checks[chk.name] = results[i]
+		if results[i].Status != "up" && chk.critical {
+			response.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	c.JSON(status, response)
+}
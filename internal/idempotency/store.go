@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"spy-cat-agency/internal/db"
+	"time"
+)
+
+type Record struct {
+	Fingerprint string
+	StatusCode  *int
+	ContentType string
+	Body        []byte
+}
+
+type Store struct {
+	conn  *db.DB
+	ttl   time.Duration
+	lease time.Duration
+}
+
+func NewStore(conn *db.DB, ttl, lease time.Duration) *Store {
+	return &Store{conn: conn, ttl: ttl, lease: lease}
+}
+
+// Reserve claims key for a new request. When the key is already taken by an unexpired request, the stored
+// record is returned instead and reserved is false. A reservation is leased: if its request has not finished
+// when the lease runs out, as when the server died while handling it, a retry of the same request takes it over.
+func (s *Store) Reserve(ctx context.Context, scope, key, fingerprint string) (record *Record, reserved bool, err error) {
+	query := `INSERT INTO idempotency_keys (scope, key, fingerprint, expires_at, locked_until) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (scope, key) DO UPDATE
+			SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, content_type = NULL, response_body = NULL,
+				created_at = NOW(), expires_at = EXCLUDED.expires_at, locked_until = EXCLUDED.locked_until
+			WHERE idempotency_keys.expires_at < NOW()
+				OR (idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until < NOW()
+					AND idempotency_keys.fingerprint = EXCLUDED.fingerprint)`
+
+	now := time.Now()
+	res, err := s.conn.ExecContext(ctx, query, scope, key, fingerprint, now.Add(s.ttl), now.Add(s.lease))
+	if err != nil {
+		return nil, false, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if rowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	query = `SELECT fingerprint, status_code, COALESCE(content_type, ''), response_body
+		FROM idempotency_keys WHERE scope = $1 AND key = $2`
+
+	var r Record
+	err = s.conn.QueryRowContext(ctx, query, scope, key).Scan(&r.Fingerprint, &r.StatusCode, &r.ContentType, &r.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.Reserve(ctx, scope, key, fingerprint)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &r, false, nil
+}
+
+func (s *Store) Complete(ctx context.Context, scope, key string, status int, contentType string, body []byte) error {
+	query := `UPDATE idempotency_keys SET status_code = $3, content_type = $4, response_body = $5
+		WHERE scope = $1 AND key = $2`
+
+	_, err := s.conn.ExecContext(ctx, query, scope, key, status, contentType, body)
+	return err
+}
+
+func (s *Store) Release(ctx context.Context, scope, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status_code IS NULL`
+
+	_, err := s.conn.ExecContext(ctx, query, scope, key)
+	return err
+}
+
+func (s *Store) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `DELETE FROM idempotency_keys WHERE expires_at < NOW()`
+
+	res, err := s.conn.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *Store) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.DeleteExpired(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to delete expired idempotency keys", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "Deleted expired idempotency keys", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+)
+
+var level = new(slog.LevelVar)
+
+func New(levelName string) (*slog.Logger, error) {
+	if err := SetLevel(levelName); err != nil {
+		return nil, err
+	}
+
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+func SetLevel(levelName string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(levelName)); err != nil {
+		return err
+	}
+
+	level.Set(l)
+
+	return nil
+}
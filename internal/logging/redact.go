@@ -0,0 +1,188 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const Redacted = "[REDACTED]"
+
+type segmentKind int
+
+const (
+	childSegment segmentKind = iota
+	indexSegment
+	wildcardSegment
+	descendantSegment
+)
+
+type segment struct {
+	kind  segmentKind
+	name  string
+	index int
+}
+
+// Redactor masks the values addressed by a set of JSONPath-style rules, for example
+// "$.salary", "$.targets[*].notes" or "$..notes".
+type Redactor struct {
+	rules [][]segment
+}
+
+func NewRedactor(rules []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, rule := range rules {
+		segments, err := parsePath(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule %q: %w", rule, err)
+		}
+		r.rules = append(r.rules, segments)
+	}
+
+	return r, nil
+}
+
+func (r *Redactor) Redact(body []byte) []byte {
+	if len(r.rules) == 0 || len(bytes.TrimSpace(body)) == 0 {
+		return body
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return body
+	}
+
+	for _, rule := range r.rules {
+		document = apply(document, rule)
+	}
+
+	redacted, err := json.Marshal(document)
+	if err != nil {
+		return body
+	}
+
+	return redacted
+}
+
+func apply(node any, path []segment) any {
+	if len(path) == 0 {
+		return Redacted
+	}
+
+	seg, rest := path[0], path[1:]
+
+	switch seg.kind {
+	case childSegment:
+		if m, ok := node.(map[string]any); ok {
+			if v, ok := m[seg.name]; ok {
+				m[seg.name] = apply(v, rest)
+			}
+		}
+	case indexSegment:
+		if a, ok := node.([]any); ok && seg.index < len(a) {
+			a[seg.index] = apply(a[seg.index], rest)
+		}
+	case wildcardSegment:
+		switch n := node.(type) {
+		case map[string]any:
+			for k, v := range n {
+				n[k] = apply(v, rest)
+			}
+		case []any:
+			for i, v := range n {
+				n[i] = apply(v, rest)
+			}
+		}
+	case descendantSegment:
+		switch n := node.(type) {
+		case map[string]any:
+			for k, v := range n {
+				// A match can hold further matches, as in $..a.b over {"a": {"c": {"a": {"b": 1}}}}.
+				v = apply(v, path)
+				if k == seg.name {
+					v = apply(v, rest)
+				}
+				n[k] = v
+			}
+		case []any:
+			for i, v := range n {
+				n[i] = apply(v, path)
+			}
+		}
+	}
+
+	return node
+}
+
+func parsePath(path string) ([]segment, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path must start with $")
+	}
+
+	var segments []segment
+	rest := path[1:]
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			name, remaining := readName(rest[2:])
+			if name == "" {
+				return nil, fmt.Errorf("missing name after ..")
+			}
+			segments = append(segments, segment{kind: descendantSegment, name: name})
+			rest = remaining
+		case strings.HasPrefix(rest, ".*"):
+			segments = append(segments, segment{kind: wildcardSegment})
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "."):
+			name, remaining := readName(rest[1:])
+			if name == "" {
+				return nil, fmt.Errorf("missing name after .")
+			}
+			segments = append(segments, segment{kind: childSegment, name: name})
+			rest = remaining
+		case strings.HasPrefix(rest, "["):
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [")
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+
+			if inner == "*" {
+				segments = append(segments, segment{kind: wildcardSegment})
+				continue
+			}
+			if unquoted, ok := strings.CutPrefix(inner, "'"); ok && strings.HasSuffix(unquoted, "'") {
+				segments = append(segments, segment{kind: childSegment, name: strings.TrimSuffix(unquoted, "'")})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index %q", inner)
+			}
+			segments = append(segments, segment{kind: indexSegment, index: index})
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("path selects the whole document")
+	}
+
+	return segments, nil
+}
+
+func readName(s string) (string, string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
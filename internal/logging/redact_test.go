@@ -0,0 +1,119 @@
+package logging
+
+import "testing"
+
+func TestRedactor(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		body  string
+		want  string
+	}{
+		{
+			name:  "child",
+			rules: []string{"$.salary"},
+			body:  `{"name":"Tom","salary":1500}`,
+			want:  `{"name":"Tom","salary":"[REDACTED]"}`,
+		},
+		{
+			name:  "nested child",
+			rules: []string{"$.cat.salary"},
+			body:  `{"cat":{"salary":1500},"salary":10}`,
+			want:  `{"cat":{"salary":"[REDACTED]"},"salary":10}`,
+		},
+		{
+			name:  "array wildcard",
+			rules: []string{"$.cats[*].salary"},
+			body:  `{"cats":[{"id":1,"salary":1500},{"id":2,"salary":2000},{"id":3}]}`,
+			want:  `{"cats":[{"id":1,"salary":"[REDACTED]"},{"id":2,"salary":"[REDACTED]"},{"id":3}]}`,
+		},
+		{
+			name:  "array index",
+			rules: []string{"$.targets[1].notes"},
+			body:  `{"targets":[{"notes":"a"},{"notes":"b"}]}`,
+			want:  `{"targets":[{"notes":"a"},{"notes":"[REDACTED]"}]}`,
+		},
+		{
+			name:  "index out of range",
+			rules: []string{"$.targets[5].notes"},
+			body:  `{"targets":[{"notes":"a"}]}`,
+			want:  `{"targets":[{"notes":"a"}]}`,
+		},
+		{
+			name:  "quoted name",
+			rules: []string{"$['api-key']"},
+			body:  `{"api-key":"sk_1","id":1}`,
+			want:  `{"api-key":"[REDACTED]","id":1}`,
+		},
+		{
+			name:  "object wildcard",
+			rules: []string{"$.secrets.*"},
+			body:  `{"secrets":{"a":1,"b":[2]}}`,
+			want:  `{"secrets":{"a":"[REDACTED]","b":"[REDACTED]"}}`,
+		},
+		{
+			name:  "descendant at every depth",
+			rules: []string{"$..notes"},
+			body:  `{"notes":"a","targets":[{"notes":"b"},{"items":[{"notes":"c"}]}]}`,
+			want:  `{"notes":"[REDACTED]","targets":[{"notes":"[REDACTED]"},{"items":[{"notes":"[REDACTED]"}]}]}`,
+		},
+		{
+			name:  "descendant with a child",
+			rules: []string{"$..secret.token"},
+			body:  `{"secret":{"token":"t1","id":1},"list":[{"secret":{"token":"t2"}}]}`,
+			want:  `{"list":[{"secret":{"token":"[REDACTED]"}}],"secret":{"id":1,"token":"[REDACTED]"}}`,
+		},
+		{
+			name:  "descendant inside a match",
+			rules: []string{"$..secret.token"},
+			body:  `{"secret":{"token":"t1","nested":{"secret":{"token":"t2"}}}}`,
+			want:  `{"secret":{"nested":{"secret":{"token":"[REDACTED]"}},"token":"[REDACTED]"}}`,
+		},
+		{
+			name:  "descendant inside an array match",
+			rules: []string{"$..targets[*].notes"},
+			body:  `{"targets":[{"notes":"a","targets":[{"notes":"b"}]}]}`,
+			want:  `{"targets":[{"notes":"[REDACTED]","targets":[{"notes":"[REDACTED]"}]}]}`,
+		},
+		{
+			name:  "several rules",
+			rules: []string{"$.salary", "$..notes"},
+			body:  `{"salary":1,"notes":"a"}`,
+			want:  `{"notes":"[REDACTED]","salary":"[REDACTED]"}`,
+		},
+		{
+			name:  "not JSON",
+			rules: []string{"$.salary"},
+			body:  `salary=1500`,
+			want:  `salary=1500`,
+		},
+		{
+			name: "no rules",
+			body: `{"salary":1500}`,
+			want: `{"salary":1500}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRedactor(tt.rules)
+			if err != nil {
+				t.Fatalf("NewRedactor() error = %v", err)
+			}
+
+			if got := string(r.Redact([]byte(tt.body))); got != tt.want {
+				t.Errorf("Redact() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRedactorInvalidRule(t *testing.T) {
+	for _, rule := range []string{"salary", "$", "$.", "$..", "$[1", "$[-1]", "$[x]", "$salary"} {
+		t.Run(rule, func(t *testing.T) {
+			if _, err := NewRedactor([]string{rule}); err == nil {
+				t.Errorf("NewRedactor(%q) accepted the rule", rule)
+			}
+		})
+	}
+}
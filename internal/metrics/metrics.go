@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const namespace = "spycat"
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests handled, by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests, by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	Panics = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_panics_total",
+		Help:      "Number of panics recovered while handling HTTP requests.",
+	})
+
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_rejected_total",
+		Help:      "Number of requests shed by the rate limiter, by reason (rate or concurrency).",
+	}, []string{"reason"})
+
+	BreedCatalogDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "breed_catalog_request_duration_seconds",
+		Help:      "Latency of calls to the external breed catalog, by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	BreedCatalogErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "breed_catalog_errors_total",
+		Help:      "Number of failed calls to the external breed catalog.",
+	})
+
+	OutboxPublishAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_publish_attempts_total",
+		Help:      "Number of attempts to publish outbox events, by event type and outcome.",
+	}, []string{"event_type", "outcome"})
+
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Number of webhook delivery attempts, by outcome.",
+	}, []string{"outcome"})
+
+	StreamClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stream_clients",
+		Help:      "Number of open event streams.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		Panics,
+		RateLimited,
+		BreedCatalogDuration,
+		BreedCatalogErrors,
+		OutboxPublishAttempts,
+		WebhookDeliveries,
+		StreamClients,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+func RegisterDB(conn *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(conn, name))
+}
+
+type CountFunc func(ctx context.Context) (int, error)
+
+func RegisterBusinessGauges(activeMissions, idleCats CountFunc) {
+	Registry.MustRegister(&businessCollector{
+		gauges: []businessGauge{
+			newBusinessGauge("active_missions", "Number of incomplete missions assigned to a cat.", activeMissions),
+			newBusinessGauge("idle_cats", "Number of cats without an incomplete mission.", idleCats),
+		},
+	})
+}
+
+type businessGauge struct {
+	name  string
+	desc  *prometheus.Desc
+	count CountFunc
+}
+
+func newBusinessGauge(name, help string, count CountFunc) businessGauge {
+	name = namespace + "_" + name
+	return businessGauge{
+		name:  name,
+		desc:  prometheus.NewDesc(name, help, nil, nil),
+		count: count,
+	}
+}
+
+type businessCollector struct {
+	gauges []businessGauge
+}
+
+func (b *businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, g := range b.gauges {
+		ch <- g.desc
+	}
+}
+
+func (b *businessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, g := range b.gauges {
+		n, err := g.count(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to collect business metric", "metric", g.name, "error", err)
+			ch <- prometheus.NewInvalidMetric(g.desc, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, float64(n))
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"spy-cat-agency/internal/apikey"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/problem"
+	"strings"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+func Authenticate(verifier *auth.Verifier, keys *apikey.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var principal *auth.Principal
+		var err error
+
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			principal, err = keys.Authenticate(c.Request.Context(), key)
+			switch {
+			case errors.Is(err, apikey.InvalidKeyErr):
+				_ = c.Error(err).SetType(gin.ErrorTypePrivate)
+				unauthenticated(c, `Bearer realm="spy-cat-agency"`, "The API key is invalid, expired or revoked.")
+				return
+			case err != nil:
+				_ = c.Error(err)
+				c.Abort()
+				return
+			}
+		} else {
+			scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+			if !strings.EqualFold(scheme, "Bearer") || token == "" {
+				unauthenticated(c, `Bearer realm="spy-cat-agency"`, "A bearer token or API key is required.")
+				return
+			}
+
+			principal, err = verifier.Verify(strings.TrimSpace(token))
+			if err != nil {
+				_ = c.Error(err).SetType(gin.ErrorTypePrivate)
+				unauthenticated(c, `Bearer realm="spy-cat-agency", error="invalid_token"`, "The bearer token is invalid or expired.")
+				return
+			}
+		}
+
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(
+			attribute.String("enduser.id", principal.Subject),
+			attribute.String("enduser.role", string(principal.Role)),
+		)
+
+		c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), principal))
+
+		c.Next()
+	}
+}
+
+func Require(perm auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := auth.Authorize(c.Request.Context(), perm); err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func unauthenticated(c *gin.Context, challenge, detail string) {
+	c.Header("WWW-Authenticate", challenge)
+	problem.Write(c, problem.New(http.StatusUnauthorized, problem.CodeUnauthenticated, detail))
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"spy-cat-agency/internal/db"
+	"strings"
+)
+
+const ConsistencyHeader = "X-Consistency"
+
+func Consistency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.EqualFold(c.GetHeader(ConsistencyHeader), "strong") {
+			c.Request = c.Request.WithContext(db.WithPrimary(c.Request.Context()))
+		}
+
+		c.Next()
+	}
+}
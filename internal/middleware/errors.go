@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"spy-cat-agency/internal/problem"
+)
+
+func Problems() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		writeErrors(c)
+	}
+}
+
+func writeErrors(c *gin.Context) {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+
+	err := c.Errors.Last()
+	if err.IsType(gin.ErrorTypeBind) {
+		problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest,
+			"The request body is invalid or missing required fields."))
+		return
+	}
+
+	problem.Write(c, problem.FromError(err.Err))
+}
+
+func NoRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeRouteNotFound,
+			"The requested resource does not exist."))
+	}
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"spy-cat-agency/internal/idempotency"
+	"spy-cat-agency/internal/problem"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+)
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyStore keeps the reservations and the stored responses behind Idempotency. *idempotency.Store keeps
+// them in Postgres.
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, scope, key, fingerprint string) (record *idempotency.Record, reserved bool, err error)
+	Complete(ctx context.Context, scope, key string, status int, contentType string, body []byte) error
+	Release(ctx context.Context, scope, key string) error
+}
+
+func Idempotency(store IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLen {
+			problem.Write(c, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest,
+				"The Idempotency-Key header must not be longer than 255 characters."))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			_ = c.Error(err).SetType(gin.ErrorTypeBind)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		scope := client(c)
+		fingerprint := fingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		record, reserved, err := store.Reserve(ctx, scope, key, fingerprint)
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.Fingerprint != fingerprint:
+				problem.Write(c, problem.New(http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused,
+					"The Idempotency-Key was already used for a different request."))
+			case record.StatusCode == nil:
+				c.Header("Retry-After", "1")
+				problem.Write(c, problem.New(http.StatusConflict, problem.CodeIdempotencyInProgress,
+					"A request with this Idempotency-Key is still being processed."))
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(*record.StatusCode, record.ContentType, record.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		// A detached context lets the outcome be stored even if the client has already gone away.
+		ctx = context.WithoutCancel(ctx)
+
+		defer func() {
+			if r := recover(); r != nil {
+				_ = store.Release(ctx, scope, key)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		// Errors are normally rendered by Problems on the way out; render them now so they are stored too.
+		writeErrors(c)
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			err = store.Release(ctx, scope, key)
+		} else {
+			err = store.Complete(ctx, scope, key, status, c.Writer.Header().Get("Content-Type"), recorder.body.Bytes())
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to store idempotent response", "idempotency_key", key, "error", err)
+		}
+	}
+}
+
+func fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,238 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"spy-cat-agency/internal/idempotency"
+	"spy-cat-agency/internal/problem"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testLease = time.Minute
+
+type storedKey struct {
+	idempotency.Record
+	lockedUntil time.Time
+}
+
+// fakeStore keeps keys in memory with the takeover rule of the Postgres store: an unfinished reservation whose
+// lease has run out goes to a retry of the same request.
+type fakeStore struct {
+	mu   sync.Mutex
+	keys map[string]*storedKey
+	now  time.Time
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{keys: make(map[string]*storedKey), now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+}
+
+func (s *fakeStore) Reserve(_ context.Context, scope, key, fingerprint string) (*idempotency.Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[scope+"|"+key]
+	if ok && (k.StatusCode != nil || !k.lockedUntil.Before(s.now) || k.Fingerprint != fingerprint) {
+		record := k.Record
+		return &record, false, nil
+	}
+
+	s.keys[scope+"|"+key] = &storedKey{
+		Record:      idempotency.Record{Fingerprint: fingerprint},
+		lockedUntil: s.now.Add(testLease),
+	}
+	return nil, true, nil
+}
+
+func (s *fakeStore) Complete(_ context.Context, scope, key string, status int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := s.keys[scope+"|"+key]
+	k.StatusCode = &status
+	k.ContentType = contentType
+	k.Body = body
+	return nil
+}
+
+func (s *fakeStore) Release(_ context.Context, scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if k, ok := s.keys[scope+"|"+key]; ok && k.StatusCode == nil {
+		delete(s.keys, scope+"|"+key)
+	}
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	type step struct {
+		body     string
+		status   int // what the handler answers, if it runs
+		advance  time.Duration
+		want     int
+		code     string
+		replayed bool
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		// inFlight leaves a reservation of the first step's request without a response, as a request that is
+		// still running, or whose server died, would.
+		inFlight bool
+		steps    []step
+		calls    int
+	}{
+		{
+			name: "without a key",
+			steps: []step{
+				{body: `{"name":"Tom"}`, status: http.StatusCreated, want: http.StatusCreated},
+				{body: `{"name":"Tom"}`, status: http.StatusCreated, want: http.StatusCreated},
+			},
+			calls: 2,
+		},
+		{
+			name: "key too long",
+			key:  strings.Repeat("k", maxIdempotencyKeyLen+1),
+			steps: []step{
+				{body: `{"name":"Tom"}`, want: http.StatusBadRequest, code: problem.CodeInvalidRequest},
+			},
+		},
+		{
+			name: "replays the stored response",
+			key:  "create-tom",
+			steps: []step{
+				{body: `{"name":"Tom"}`, status: http.StatusCreated, want: http.StatusCreated},
+				{body: `{"name":"Tom"}`, status: http.StatusCreated, want: http.StatusCreated, replayed: true},
+				{body: `{"name":"Tom"}`, status: http.StatusCreated, advance: 2 * testLease, want: http.StatusCreated, replayed: true},
+			},
+			calls: 1,
+		},
+		{
+			name: "replays client errors",
+			key:  "create-tom",
+			steps: []step{
+				{body: `{"name":"Tom"}`, status: http.StatusBadRequest, want: http.StatusBadRequest},
+				{body: `{"name":"Tom"}`, status: http.StatusCreated, want: http.StatusBadRequest, replayed: true},
+			},
+			calls: 1,
+		},
+		{
+			name: "different body",
+			key:  "create-tom",
+			steps: []step{
+				{body: `{"name":"Tom"}`, status: http.StatusCreated, want: http.StatusCreated},
+				{body: `{"name":"Jerry"}`, status: http.StatusCreated, want: http.StatusUnprocessableEntity, code: problem.CodeIdempotencyKeyReused},
+			},
+			calls: 1,
+		},
+		{
+			name: "server errors release the key",
+			key:  "create-tom",
+			steps: []step{
+				{body: `{"name":"Tom"}`, status: http.StatusInternalServerError, want: http.StatusInternalServerError},
+				{body: `{"name":"Tom"}`, status: http.StatusCreated, want: http.StatusCreated},
+			},
+			calls: 2,
+		},
+		{
+			name:     "in progress",
+			key:      "create-tom",
+			inFlight: true,
+			steps: []step{
+				{body: `{"name":"Tom"}`, status: http.StatusCreated, want: http.StatusConflict, code: problem.CodeIdempotencyInProgress},
+			},
+		},
+		{
+			name:     "takes over once the lease has expired",
+			key:      "create-tom",
+			inFlight: true,
+			steps: []step{
+				{body: `{"name":"Tom"}`, status: http.StatusCreated, advance: 2 * testLease, want: http.StatusCreated},
+				{body: `{"name":"Tom"}`, status: http.StatusCreated, want: http.StatusCreated, replayed: true},
+			},
+			calls: 1,
+		},
+		{
+			name:     "no takeover by a different request",
+			key:      "create-tom",
+			inFlight: true,
+			steps: []step{
+				{body: `{"name":"Jerry"}`, status: http.StatusCreated, advance: 2 * testLease, want: http.StatusUnprocessableEntity, code: problem.CodeIdempotencyKeyReused},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			if tt.inFlight {
+				fp := fingerprint(http.MethodPost, "/cats", []byte(`{"name":"Tom"}`))
+				if _, _, err := store.Reserve(context.Background(), "ip:192.0.2.1", tt.key, fp); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			calls := 0
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/cats", Idempotency(store), func(c *gin.Context) {
+				calls++
+				status, _ := strconv.Atoi(c.GetHeader("X-Test-Status"))
+				c.JSON(status, gin.H{"id": calls})
+			})
+
+			var first string
+			for i, s := range tt.steps {
+				store.now = store.now.Add(s.advance)
+
+				r := httptest.NewRequest(http.MethodPost, "/cats", strings.NewReader(s.body))
+				r.RemoteAddr = "192.0.2.1:1234"
+				r.Header.Set("X-Test-Status", strconv.Itoa(s.status))
+				if tt.key != "" {
+					r.Header.Set(IdempotencyKeyHeader, tt.key)
+				}
+
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, r)
+
+				if w.Code != s.want {
+					t.Errorf("step %d: status = %d, want %d", i, w.Code, s.want)
+				}
+				if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != s.replayed {
+					t.Errorf("step %d: replayed = %t, want %t", i, replayed, s.replayed)
+				}
+				if s.replayed && w.Body.String() != first {
+					t.Errorf("step %d: replayed body %s, want %s", i, w.Body, first)
+				}
+				if s.code != "" {
+					var p struct {
+						Code string `json:"code"`
+					}
+					if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || p.Code != s.code {
+						t.Errorf("step %d: body %s, want a problem with code %q", i, w.Body, s.code)
+					}
+				}
+				if s.want == http.StatusConflict && w.Header().Get("Retry-After") == "" {
+					t.Errorf("step %d: no Retry-After on 409", i)
+				}
+
+				if first == "" && !s.replayed && s.code == "" {
+					first = w.Body.String()
+				}
+			}
+
+			if calls != tt.calls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
@@ -4,22 +4,81 @@ import (
 	"bytes"
 	"github.com/gin-gonic/gin"
 	"io"
-	"log"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/logging"
+	"sync/atomic"
 	"time"
 )
 
 type bodyLogWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	body  *bytes.Buffer
+	limit int
+	size  int
 }
 
-func (w bodyLogWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	w.capture(b)
 	return w.ResponseWriter.Write(b)
 }
 
-func Logger() gin.HandlerFunc {
+func (w *bodyLogWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to lift the write deadline of a stream.
+func (w *bodyLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *bodyLogWriter) capture(b []byte) {
+	w.size += len(b)
+	if room := w.limit - w.body.Len(); room > 0 {
+		w.body.Write(b[:min(room, len(b))])
+	}
+}
+
+type loggerSettings struct {
+	maxBodyBytes int
+	sampleRate   float64
+	redactor     *logging.Redactor
+}
+
+type RequestLogger struct {
+	settings atomic.Pointer[loggerSettings]
+}
+
+func NewRequestLogger(cfg config.LogConfig) (*RequestLogger, error) {
+	l := &RequestLogger{}
+	if err := l.Update(cfg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *RequestLogger) Update(cfg config.LogConfig) error {
+	redactor, err := logging.NewRedactor(cfg.Redact)
+	if err != nil {
+		return err
+	}
+
+	l.settings.Store(&loggerSettings{
+		maxBodyBytes: cfg.MaxBodyBytes,
+		sampleRate:   cfg.SampleRate,
+		redactor:     redactor,
+	})
+
+	return nil
+}
+
+func (l *RequestLogger) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		cfg := l.settings.Load()
+
 		var requestBodyBytes []byte
 		if c.Request.Body != nil {
 			requestBodyBytes, _ = io.ReadAll(c.Request.Body)
@@ -27,25 +86,58 @@ func Logger() gin.HandlerFunc {
 
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBodyBytes))
 
-		log.Printf(
-			"Request: Method=%s, Path=%s, Body=%s",
-			c.Request.Method,
-			c.Request.URL.Path,
-			string(requestBodyBytes),
-		)
-
-		blw := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
+		blw := &bodyLogWriter{body: &bytes.Buffer{}, limit: cfg.maxBodyBytes, ResponseWriter: c.Writer}
 		c.Writer = blw
 
 		t := time.Now()
 
 		c.Next()
 
-		log.Printf(
-			"Response: StatusCode=%d, Latency=%v, Body=%s",
-			c.Writer.Status(),
-			time.Since(t),
-			blw.body.String(),
-		)
+		status := c.Writer.Status()
+		if status < 400 && rand.Float64() >= cfg.sampleRate {
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", route),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(t)),
+			body("request_body", cfg.redactor, requestBodyBytes, len(requestBodyBytes), cfg.maxBodyBytes),
+			body("response_body", cfg.redactor, blw.body.Bytes(), blw.size, cfg.maxBodyBytes),
+		}
+
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.Any("errors", c.Errors.Errors()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		slog.LogAttrs(c.Request.Context(), level, "Request handled", attrs...)
 	}
 }
+
+func body(key string, redactor *logging.Redactor, b []byte, size, limit int) slog.Attr {
+	if size == 0 {
+		return slog.String(key, "")
+	}
+
+	if size > limit {
+		return slog.Group(key, slog.Int("size", size), slog.Bool("omitted", true))
+	}
+
+	return slog.String(key, string(redactor.Redact(b)))
+}
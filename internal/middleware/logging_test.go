@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/pflag"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/apikey"
+	"spy-cat-agency/internal/audit"
+	"spy-cat-agency/internal/cat"
+	"spy-cat-agency/internal/change"
+	"spy-cat-agency/internal/mission"
+	"spy-cat-agency/internal/webhook"
+	"strings"
+	"testing"
+)
+
+// logConfigs returns the log settings of the built-in defaults and of the shipped config.yaml, which both
+// have to carry the redaction rules.
+func logConfigs(t *testing.T) map[string]config.LogConfig {
+	t.Helper()
+
+	configs := make(map[string]config.LogConfig)
+	for name, args := range map[string][]string{
+		"defaults":    nil,
+		"config.yaml": {"--config", "../../config/config.yaml"},
+	} {
+		c, err := config.Load(pflag.NewFlagSet("test", pflag.ContinueOnError), args)
+		if err != nil {
+			t.Fatalf("loading the %s: %v", name, err)
+		}
+		configs[name] = c.Log
+	}
+
+	return configs
+}
+
+// captureLogs sends the default logger to a buffer for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return &buf
+}
+
+func TestRequestLoggerRedaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		response any
+		secrets  []string
+	}{
+		{
+			name:   "created api key",
+			method: http.MethodPost,
+			path:   "/api/v1/api-keys",
+			response: apikey.CreateAPIKeyResponse{
+				APIKeyResponse: apikey.APIKeyResponse{ID: 1, Name: "dashboard", Prefix: "sca_3f9a"},
+				Key:            "sca_3f9a_c2VjcmV0LWtleS1tYXRlcmlhbA",
+			},
+			secrets: []string{"c2VjcmV0LWtleS1tYXRlcmlhbA"},
+		},
+		{
+			name:   "created webhook",
+			method: http.MethodPost,
+			path:   "/api/v1/webhooks",
+			response: webhook.CreateWebhookResponse{
+				WebhookResponse: webhook.WebhookResponse{ID: 1, URL: "https://example.com/hooks"},
+				Secret:          "whsec_c2lnbmluZy1zZWNyZXQ",
+			},
+			secrets: []string{"c2lnbmluZy1zZWNyZXQ"},
+		},
+		{
+			name:   "webhook deliveries",
+			method: http.MethodGet,
+			path:   "/api/v1/webhooks/1/deliveries",
+			response: webhook.ListDeliveriesResponse{Deliveries: []webhook.DeliveryResponse{{
+				ID:        1,
+				EventType: "cat.salary_changed",
+				Payload:   json.RawMessage(`{"type":"cat.salary_changed","payload":{"cat_id":3,"old_salary":91234,"new_salary":95678}}`),
+			}}},
+			secrets: []string{"91234", "95678"},
+		},
+		{
+			name:     "redelivery",
+			method:   http.MethodPost,
+			path:     "/api/v1/webhooks/1/deliveries/1/redeliver",
+			response: webhook.DeliveryResponse{ID: 1, Payload: json.RawMessage(`{"payload":{"new_salary":95678}}`)},
+			secrets:  []string{"95678"},
+		},
+		{
+			name:   "audit snapshots",
+			method: http.MethodGet,
+			path:   "/api/v1/audit",
+			response: audit.ListEventsResponse{Events: []audit.EventResponse{{
+				ID:         1,
+				EntityType: "cat",
+				Action:     audit.ActionUpdate,
+				Before:     json.RawMessage(`{"salary":81234,"version":2}`),
+				After:      json.RawMessage(`{"salary":85678,"version":3}`),
+			}}},
+			secrets: []string{"81234", "85678"},
+		},
+		{
+			name:   "change feed data",
+			method: http.MethodGet,
+			path:   "/api/v1/changes",
+			response: change.ListChangesResponse{Changes: []change.ChangeResponse{{
+				EntityType: "cat",
+				EntityID:   3,
+				Operation:  change.OperationUpdate,
+				Version:    4,
+				Data:       json.RawMessage(`{"id":3,"name":"Tom","salary":61234,"version":4}`),
+			}}},
+			secrets: []string{"61234"},
+		},
+		{
+			name:     "updated salary",
+			method:   http.MethodPatch,
+			path:     "/api/v1/cats/3",
+			response: cat.UpdateCatSalaryResponse{ID: 3, NewSalary: 71234},
+			secrets:  []string{"71234"},
+		},
+	}
+
+	for source, cfg := range logConfigs(t) {
+		for _, tt := range tests {
+			t.Run(source+"/"+tt.name, func(t *testing.T) {
+				logs := captureLogs(t)
+
+				logger, err := NewRequestLogger(cfg)
+				if err != nil {
+					t.Fatalf("NewRequestLogger() error = %v", err)
+				}
+
+				gin.SetMode(gin.TestMode)
+				router := gin.New()
+				router.Use(logger.Handler())
+				router.Handle(tt.method, tt.path, func(c *gin.Context) {
+					c.JSON(http.StatusOK, tt.response)
+				})
+
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+				for _, secret := range tt.secrets {
+					if !strings.Contains(w.Body.String(), secret) {
+						t.Fatalf("response %s does not contain %q", w.Body, secret)
+					}
+					if strings.Contains(logs.String(), secret) {
+						t.Errorf("log output contains %q: %s", secret, logs)
+					}
+				}
+				if !strings.Contains(logs.String(), "Request handled") {
+					t.Errorf("request was not logged: %s", logs)
+				}
+			})
+		}
+	}
+}
+
+func TestRequestLoggerErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		level string
+	}{
+		{"unexpected", errors.New("connection reset by peer"), "ERROR"},
+		{"not found", mission.NotFoundErr, "WARN"},
+		{"precondition failed", mission.PreconditionFailedErr, "WARN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+
+			logger, err := NewRequestLogger(logConfigs(t)["defaults"])
+			if err != nil {
+				t.Fatalf("NewRequestLogger() error = %v", err)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(logger.Handler(), Problems())
+			router.DELETE("/api/v1/missions/:id", func(c *gin.Context) {
+				_ = c.Error(tt.err)
+			})
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/v1/missions/7", nil))
+
+			// One line for the request, carrying the error, and nothing else.
+			lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("logged %d lines, want 1: %s", len(lines), logs)
+			}
+
+			var entry struct {
+				Level  string   `json:"level"`
+				Msg    string   `json:"msg"`
+				Errors []string `json:"errors"`
+			}
+			if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+				t.Fatal(err)
+			}
+			if entry.Msg != "Request handled" || entry.Level != tt.level || len(entry.Errors) != 1 || entry.Errors[0] != tt.err.Error() {
+				t.Errorf("logged %s, want the request at %s with its error", lines[0], tt.level)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/metrics"
+	"spy-cat-agency/internal/problem"
+	"spy-cat-agency/internal/ratelimit"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type RateLimiter struct {
+	cfg      atomic.Pointer[config.RateLimitConfig]
+	limiter  *ratelimit.Limiter
+	inFlight atomic.Int64
+
+	mu          sync.Mutex
+	connections map[string]int
+}
+
+func NewRateLimiter(cfg config.RateLimitConfig) *RateLimiter {
+	l := &RateLimiter{limiter: ratelimit.New(), connections: make(map[string]int)}
+	l.Update(cfg)
+	return l
+}
+
+func (l *RateLimiter) Update(cfg config.RateLimitConfig) {
+	l.cfg.Store(&cfg)
+}
+
+func (l *RateLimiter) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, enabled := l.Allow(client(c), c.Request.Method+" "+c.FullPath())
+		if !enabled {
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(int(result.Reset.Seconds())))
+
+		if !result.Allowed {
+			reject(c, http.StatusTooManyRequests, problem.CodeRateLimited, result.RetryAfter,
+				"Too many requests; retry after the number of seconds in the Retry-After header.")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Allow takes a token for one request of client to route from the route's bucket, or from the default one.
+// Handler calls it for every HTTP request; requests that arrive some other way, like WebSocket messages, call
+// it themselves. enabled is false when rate limiting is switched off.
+func (l *RateLimiter) Allow(client, route string) (result ratelimit.Result, enabled bool) {
+	cfg := l.cfg.Load()
+	if !cfg.Enabled {
+		return ratelimit.Result{Allowed: true}, false
+	}
+
+	route = strings.ToLower(route)
+
+	limit := ratelimit.Limit{Rate: cfg.Rate, Burst: cfg.Burst}
+	bucket := "*"
+	if r, ok := cfg.Routes[route]; ok {
+		limit = ratelimit.Limit{Rate: r.Rate, Burst: r.Burst}
+		bucket = route
+	}
+
+	result = l.limiter.Allow(client+"|"+bucket, limit)
+	if !result.Allowed {
+		metrics.RateLimited.WithLabelValues("rate").Inc()
+	}
+
+	return result, true
+}
+
+// Authentication throttles clients by IP once they have failed authentication too often. It runs before
+// Authenticate, which the per-client Handler follows, so that missing, invalid and revoked credentials are
+// limited too and stop costing an API key lookup. Only answers of 401 take a token.
+func (l *RateLimiter) Authentication() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := l.cfg.Load()
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		key := "ip:" + c.ClientIP() + "|auth_failures"
+		limit := ratelimit.Limit{Rate: cfg.AuthFailureRate, Burst: cfg.AuthFailureBurst}
+
+		if result := l.limiter.Peek(key, limit); !result.Allowed {
+			metrics.RateLimited.WithLabelValues("auth_failures").Inc()
+			reject(c, http.StatusTooManyRequests, problem.CodeRateLimited, result.RetryAfter,
+				"Too many failed authentication attempts; retry after the number of seconds in the Retry-After header.")
+			return
+		}
+
+		c.Next()
+
+		if c.Writer.Status() == http.StatusUnauthorized {
+			l.limiter.Allow(key, limit)
+		}
+	}
+}
+
+func (l *RateLimiter) InFlight() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		release, ok := l.Acquire()
+		defer release()
+
+		if !ok {
+			reject(c, http.StatusServiceUnavailable, problem.CodeOverloaded, l.cfg.Load().RetryAfter,
+				"The server is handling too many requests; retry later.")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Acquire takes one of the max_in_flight slots, reporting false when all are taken. release must be called
+// either way.
+func (l *RateLimiter) Acquire() (release func(), ok bool) {
+	cfg := l.cfg.Load()
+	if !cfg.Enabled || cfg.MaxInFlight == 0 {
+		return func() {}, true
+	}
+
+	release = func() { l.inFlight.Add(-1) }
+	if l.inFlight.Add(1) > int64(cfg.MaxInFlight) {
+		metrics.RateLimited.WithLabelValues("concurrency").Inc()
+		return release, false
+	}
+
+	return release, true
+}
+
+// Connections caps how many event streams and WebSockets one client keeps open at once, so that a single
+// API key cannot take all of stream.max_clients. The slot is held until the handler returns.
+func (l *RateLimiter) Connections() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := l.cfg.Load()
+		if !cfg.Enabled || cfg.MaxConnectionsPerClient == 0 {
+			c.Next()
+			return
+		}
+
+		key := client(c)
+
+		l.mu.Lock()
+		if l.connections[key] >= cfg.MaxConnectionsPerClient {
+			l.mu.Unlock()
+			metrics.RateLimited.WithLabelValues("connections").Inc()
+			reject(c, http.StatusTooManyRequests, problem.CodeRateLimited, cfg.RetryAfter,
+				"Too many open connections for this client; close one before opening another.")
+			return
+		}
+		l.connections[key]++
+		l.mu.Unlock()
+
+		defer func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.connections[key]--; l.connections[key] == 0 {
+				delete(l.connections, key)
+			}
+		}()
+
+		c.Next()
+	}
+}
+
+func client(c *gin.Context) string {
+	if p := auth.FromContext(c.Request.Context()); p != nil {
+		return p.Subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func reject(c *gin.Context, status int, code string, retryAfter time.Duration, detail string) {
+	c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	problem.Write(c, problem.New(status, code, detail))
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"spy-cat-agency/config"
+	"testing"
+	"time"
+)
+
+func newRateLimitRouter(status int, handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handlers...)
+	router.GET("/cats", func(c *gin.Context) {
+		c.Status(status)
+	})
+	return router
+}
+
+func serve(router *gin.Engine) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cats", nil))
+	return w
+}
+
+func TestRateLimiterHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.RateLimitConfig
+		requests int
+		status   int
+		headers  map[string]string
+	}{
+		{
+			name:     "disabled",
+			cfg:      config.RateLimitConfig{Rate: 1, Burst: 1},
+			requests: 3,
+			status:   http.StatusNoContent,
+			headers:  map[string]string{"RateLimit-Limit": "", "RateLimit-Remaining": "", "Retry-After": ""},
+		},
+		{
+			name:     "within the burst",
+			cfg:      config.RateLimitConfig{Enabled: true, Rate: 1, Burst: 2},
+			requests: 1,
+			status:   http.StatusNoContent,
+			headers:  map[string]string{"RateLimit-Limit": "2", "RateLimit-Remaining": "1", "RateLimit-Reset": "1", "Retry-After": ""},
+		},
+		{
+			name:     "over the burst",
+			cfg:      config.RateLimitConfig{Enabled: true, Rate: 1, Burst: 2},
+			requests: 3,
+			status:   http.StatusTooManyRequests,
+			headers:  map[string]string{"RateLimit-Limit": "2", "RateLimit-Remaining": "0", "RateLimit-Reset": "2", "Retry-After": "1"},
+		},
+		{
+			name: "route limit",
+			cfg: config.RateLimitConfig{
+				Enabled: true, Rate: 1, Burst: 10,
+				Routes: map[string]config.RouteRate{"get /cats": {Rate: 0.5, Burst: 1}},
+			},
+			requests: 2,
+			status:   http.StatusTooManyRequests,
+			headers:  map[string]string{"RateLimit-Limit": "1", "RateLimit-Remaining": "0", "RateLimit-Reset": "2", "Retry-After": "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(tt.cfg)
+			router := newRateLimitRouter(http.StatusNoContent, l.Handler())
+
+			var w *httptest.ResponseRecorder
+			for range tt.requests {
+				w = serve(router)
+			}
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			for header, want := range tt.headers {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterAuthentication(t *testing.T) {
+	cfg := config.RateLimitConfig{Enabled: true, AuthFailureRate: 0.5, AuthFailureBurst: 2, RetryAfter: time.Second}
+
+	tests := []struct {
+		name     string
+		status   int
+		requests int
+		want     int
+	}{
+		{"successes are not counted", http.StatusOK, 5, http.StatusOK},
+		{"failures within the burst", http.StatusUnauthorized, 2, http.StatusUnauthorized},
+		{"failures beyond the burst", http.StatusUnauthorized, 3, http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(cfg)
+			router := newRateLimitRouter(tt.status, l.Authentication())
+
+			var w *httptest.ResponseRecorder
+			for range tt.requests {
+				w = serve(router)
+			}
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "2" {
+				t.Errorf("Retry-After = %q, want %q", w.Header().Get("Retry-After"), "2")
+			}
+		})
+	}
+}
+
+func TestRateLimiterConnections(t *testing.T) {
+	l := NewRateLimiter(config.RateLimitConfig{Enabled: true, MaxConnectionsPerClient: 1, RetryAfter: 3 * time.Second})
+
+	held := make(chan struct{})
+	release := make(chan struct{})
+	router := newRateLimitRouter(http.StatusNoContent, l.Connections(), func(c *gin.Context) {
+		if c.Query("hold") != "" {
+			close(held)
+			<-release
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/cats?hold=1", nil))
+	}()
+	<-held
+
+	w := serve(router)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("second connection: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("Retry-After = %q, want %q", got, "3")
+	}
+
+	close(release)
+	<-done
+
+	if w := serve(router); w.Code != http.StatusNoContent {
+		t.Errorf("after the first closed: status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"spy-cat-agency/internal/metrics"
+	"spy-cat-agency/internal/problem"
+	"spy-cat-agency/internal/requestid"
+	"time"
+)
+
+func Recovery(dumpDir string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+
+			if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rec)
+			}
+
+			metrics.Panics.Inc()
+
+			stack := debug.Stack()
+			requestID := requestid.FromContext(c.Request.Context())
+
+			ctx := c.Request.Context()
+
+			slog.ErrorContext(ctx, "Recovered from panic",
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"panic", fmt.Sprint(rec),
+				"stack", string(stack),
+			)
+
+			if dumpDir != "" {
+				path, err := writeCrashDump(dumpDir, requestID, c.Request, rec, stack)
+				if err != nil {
+					slog.ErrorContext(ctx, "Failed to write crash dump", "error", err)
+				} else {
+					slog.InfoContext(ctx, "Crash dump written", "path", path)
+				}
+			}
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+
+			problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal,
+				"An unexpected error occurred on the server."))
+		}()
+
+		c.Next()
+	}
+}
+
+func writeCrashDump(dir, requestID string, r *http.Request, rec any, stack []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	name := "crash-" + now.Format("20060102T150405.000000000")
+	if requestID != "" {
+		name += "-" + filepath.Base(requestID)
+	}
+	path := filepath.Join(dir, name+".log")
+
+	content := fmt.Sprintf(
+		"Time: %s\nRequestID: %s\nMethod: %s\nPath: %s\nPanic: %v\n\n%s",
+		now.Format(time.RFC3339Nano),
+		requestID,
+		r.Method,
+		r.URL.RequestURI(),
+		rec,
+		stack,
+	)
+
+	return path, os.WriteFile(path, []byte(content), 0o640)
+}
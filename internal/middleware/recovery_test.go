@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"spy-cat-agency/internal/problem"
+	"spy-cat-agency/internal/requestid"
+	"strings"
+	"testing"
+)
+
+const testRequestID = "3f2a9c1e-5b7d-4e8f-a1c2-d3e4f5a6b7c8"
+
+func newRecoveryRouter(dumpDir string, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Recovery(dumpDir))
+	router.GET("/api/v1/cats/:id", handler)
+	return router
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		dump    bool
+		want    int
+		problem bool
+	}{
+		{
+			name:    "panic",
+			handler: func(c *gin.Context) { panic("cat escaped") },
+			dump:    true,
+			want:    http.StatusInternalServerError,
+			problem: true,
+		},
+		{
+			name:    "panic with an error",
+			handler: func(c *gin.Context) { panic(errors.New("cat escaped")) },
+			dump:    true,
+			want:    http.StatusInternalServerError,
+			problem: true,
+		},
+		{
+			name: "panic after the response was started",
+			handler: func(c *gin.Context) {
+				c.String(http.StatusOK, "partial")
+				panic("cat escaped")
+			},
+			dump: true,
+			want: http.StatusOK,
+		},
+		{
+			name:    "without a dump directory",
+			handler: func(c *gin.Context) { panic("cat escaped") },
+			want:    http.StatusInternalServerError,
+			problem: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+
+			dumpDir := ""
+			if tt.dump {
+				// Not created yet: the middleware makes it when the first dump is written.
+				dumpDir = filepath.Join(t.TempDir(), "crash-dumps")
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/cats/3?fields=name", nil)
+			r.Header.Set(requestid.Header, testRequestID)
+			w := httptest.NewRecorder()
+			newRecoveryRouter(dumpDir, tt.handler).ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.problem {
+				if ct := w.Header().Get("Content-Type"); ct != problem.ContentType {
+					t.Errorf("Content-Type = %q, want %q", ct, problem.ContentType)
+				}
+				var p problem.Problem
+				if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+					t.Fatalf("decoding %s: %v", w.Body, err)
+				}
+				if p.Code != problem.CodeInternal || p.RequestID != testRequestID || strings.Contains(p.Detail, "cat escaped") {
+					t.Errorf("problem %+v", p)
+				}
+			}
+			if !strings.Contains(logs.String(), "Recovered from panic") {
+				t.Errorf("panic was not logged: %s", logs)
+			}
+
+			if !tt.dump {
+				return
+			}
+
+			dumps, err := filepath.Glob(filepath.Join(dumpDir, "crash-*-"+testRequestID+".log"))
+			if err != nil || len(dumps) != 1 {
+				t.Fatalf("crash dumps %v, %v; want one", dumps, err)
+			}
+			content, err := os.ReadFile(dumps[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{
+				"RequestID: " + testRequestID,
+				"Method: GET",
+				"Path: /api/v1/cats/3?fields=name",
+				"Panic: cat escaped",
+				"goroutine ",
+			} {
+				if !strings.Contains(string(content), want) {
+					t.Errorf("crash dump does not contain %q:\n%s", want, content)
+				}
+			}
+		})
+	}
+}
+
+func TestRecoveryRepanicsOnAbort(t *testing.T) {
+	dumpDir := t.TempDir()
+	router := newRecoveryRouter(dumpDir, func(c *gin.Context) { panic(http.ErrAbortHandler) })
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want %v", rec, http.ErrAbortHandler)
+		}
+		if entries, _ := os.ReadDir(dumpDir); len(entries) != 0 {
+			t.Errorf("crash dump written for an aborted request")
+		}
+	}()
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/cats/3", nil))
+	t.Error("the abort was swallowed")
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"spy-cat-agency/internal/problem"
+	"strings"
+)
+
+func Validator(specPath string) (gin.HandlerFunc, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromFile(specPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &openapi3filter.Options{
+		MultiError:         true,
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return func(c *gin.Context) {
+		route, pathParams, err := router.FindRoute(c.Request)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+
+		err = openapi3filter.ValidateRequest(c.Request.Context(), input)
+		if err != nil {
+			p := problem.New(http.StatusBadRequest, problem.CodeValidationFailed,
+				"The request does not conform to the API specification.")
+			p.Errors = violations(err)
+			problem.Write(c, p)
+			return
+		}
+
+		c.Next()
+	}, nil
+}
+
+func violations(err error) []problem.Violation {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var result []problem.Violation
+		for _, inner := range e {
+			result = append(result, violations(inner)...)
+		}
+		return result
+	case *openapi3filter.RequestError:
+		in := "body"
+		pointer := ""
+		if e.Parameter != nil {
+			in = e.Parameter.In
+			pointer = "/" + escapePointerToken(e.Parameter.Name)
+		}
+
+		if e.Err == nil {
+			return []problem.Violation{{In: in, Pointer: pointer, Reason: e.Reason}}
+		}
+
+		nested := violations(e.Err)
+		for i := range nested {
+			nested[i].In = in
+			if e.Parameter != nil {
+				nested[i].Pointer = pointer
+			}
+		}
+		return nested
+	case *openapi3.SchemaError:
+		pointer := ""
+		for _, token := range e.JSONPointer() {
+			pointer += "/" + escapePointerToken(token)
+		}
+		reason := e.Reason
+		if reason == "" {
+			reason = e.Error()
+		}
+		return []problem.Violation{{Pointer: pointer, Reason: reason}}
+	case *openapi3filter.ParseError:
+		var schemaErr *openapi3.SchemaError
+		if errors.As(e, &schemaErr) {
+			return violations(schemaErr)
+		}
+		return []problem.Violation{{Reason: e.Error()}}
+	}
+
+	return []problem.Violation{{Reason: err.Error()}}
+}
+
+func escapePointerToken(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return strings.ReplaceAll(token, "/", "~1")
+}
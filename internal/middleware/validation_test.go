@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"spy-cat-agency/internal/problem"
+	"strings"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	validator, err := Validator("../../api/openapi.yaml")
+	if err != nil {
+		t.Fatalf("Validator() error = %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(validator)
+	router.Any("/api/v1/*path", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		want     int
+		pointers []string
+		reason   string
+	}{
+		{
+			name:   "valid cat",
+			method: http.MethodPost,
+			path:   "/api/v1/cats",
+			body:   `{"name":"Tom","years_of_experience":3,"breed":"Siamese","salary":1000}`,
+			want:   http.StatusNoContent,
+		},
+		{
+			name:     "one violation",
+			method:   http.MethodPost,
+			path:     "/api/v1/cats",
+			body:     `{"name":"Tom","years_of_experience":-1,"breed":"Siamese","salary":1000}`,
+			want:     http.StatusBadRequest,
+			pointers: []string{"/years_of_experience"},
+		},
+		{
+			name:     "one pointer per violation",
+			method:   http.MethodPost,
+			path:     "/api/v1/cats",
+			body:     `{"name":"","years_of_experience":-1,"breed":"Siamese","salary":-5}`,
+			want:     http.StatusBadRequest,
+			pointers: []string{"/name", "/salary", "/years_of_experience"},
+		},
+		{
+			name:     "nested violation",
+			method:   http.MethodPost,
+			path:     "/api/v1/missions",
+			body:     `{"targets":[{"name":"Jerry"}]}`,
+			want:     http.StatusBadRequest,
+			pointers: []string{"/targets/0/country"},
+		},
+		{
+			name:   "valid mission update",
+			method: http.MethodPatch,
+			path:   "/api/v1/missions/7",
+			body:   `{"complete":true}`,
+			want:   http.StatusNoContent,
+		},
+		{
+			name:     "unknown property on a mission update",
+			method:   http.MethodPatch,
+			path:     "/api/v1/missions/7",
+			body:     `{"complete":true,"priority":"high"}`,
+			want:     http.StatusBadRequest,
+			pointers: []string{""},
+			reason:   `property "priority" is unsupported`,
+		},
+		{
+			name:   "route outside the specification",
+			method: http.MethodGet,
+			path:   "/api/v1/unknown",
+			want:   http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusBadRequest {
+				return
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != problem.ContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, problem.ContentType)
+			}
+
+			var p problem.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("decoding %s: %v", w.Body, err)
+			}
+			if p.Status != http.StatusBadRequest || p.Code != problem.CodeValidationFailed {
+				t.Errorf("problem %d %s, want %d %s", p.Status, p.Code, http.StatusBadRequest, problem.CodeValidationFailed)
+			}
+
+			var pointers []string
+			for _, v := range p.Errors {
+				if v.In != "body" || v.Reason == "" {
+					t.Errorf("violation %+v", v)
+				}
+				if tt.reason != "" && v.Reason != tt.reason {
+					t.Errorf("reason %q, want %q", v.Reason, tt.reason)
+				}
+				pointers = append(pointers, v.Pointer)
+			}
+			slices.Sort(pointers)
+			if !slices.Equal(pointers, tt.pointers) {
+				t.Errorf("pointers %q, want %q: %s", pointers, tt.pointers, w.Body)
+			}
+		})
+	}
+}
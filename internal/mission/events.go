@@ -0,0 +1,30 @@
+package mission
+
+type MissionCreatedEvent struct {
+	MissionID int `json:"mission_id"`
+}
+
+// MissionAssignedEvent names the cat the mission was taken from as PreviousCatID, so that it reaches that cat's
+// field agents too.
+type MissionAssignedEvent struct {
+	MissionID     int  `json:"mission_id"`
+	CatID         int  `json:"cat_id"`
+	PreviousCatID *int `json:"previous_cat_id"`
+}
+
+type MissionCompletedEvent struct {
+	MissionID int  `json:"mission_id"`
+	CatID     *int `json:"cat_id"`
+}
+
+type MissionDeletedEvent struct {
+	MissionID int `json:"mission_id"`
+}
+
+// TargetEvent is the payload of target.added, target.updated, target.completed and target.deleted. CatID is the
+// cat assigned to the mission at the time, so that consumers can follow one cat's missions.
+type TargetEvent struct {
+	MissionID int  `json:"mission_id"`
+	TargetID  int  `json:"target_id"`
+	CatID     *int `json:"cat_id"`
+}
@@ -1,10 +1,8 @@
 package mission
 
 import (
-	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
-	"spy-cat-agency/internal/cat"
+	"spy-cat-agency/internal/etag"
 	"strconv"
 )
 
@@ -19,7 +17,7 @@ type ListMissionsResponse struct {
 }
 
 type CreateMissionRequest struct {
-	Targets []TargetRequest `json:"targets" binding:"required,min=1,max=3"`
+	Targets []TargetRequest `json:"targets"`
 }
 
 type CreateMissionResponse struct {
@@ -45,8 +43,8 @@ type UpdateMissionResponse struct {
 }
 
 type TargetRequest struct {
-	Name    string `json:"name" binding:"required"`
-	Country string `json:"country" binding:"required"`
+	Name    string `json:"name"`
+	Country string `json:"country"`
 }
 
 type AddTargetResponse struct {
@@ -54,8 +52,8 @@ type AddTargetResponse struct {
 }
 
 type UpdateTargetRequest struct {
-	Notes    string `json:"notes" binding:"required"`
-	Complete bool   `json:"complete" binding:"required"`
+	Notes    string `json:"notes"`
+	Complete bool   `json:"complete"`
 }
 
 type UpdateTargetResponse struct {
@@ -73,9 +71,11 @@ func NewHandler(missionService *Service) *Handler {
 }
 
 func (h *Handler) ListMissions(c *gin.Context) {
-	missions, err := h.MissionService.ListMissions()
+	ctx := c.Request.Context()
+
+	missions, err := h.MissionService.ListMissions(ctx)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server"})
+		_ = c.Error(err)
 		return
 	}
 
@@ -98,13 +98,15 @@ func (h *Handler) CreateMission(c *gin.Context) {
 	var missionRequest CreateMissionRequest
 	err := c.ShouldBindJSON(&missionRequest)
 	if err != nil {
-		c.JSON(400, gin.H{"error": "The request body is invalid or missing required fields"})
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	id, err := h.MissionService.CreateMission(missionRequest.Targets)
+	ctx := c.Request.Context()
+
+	id, err := h.MissionService.CreateMission(ctx, missionRequest.Targets)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server"})
+		_ = c.Error(err)
 		return
 	}
 
@@ -119,18 +121,19 @@ func (h *Handler) GetMission(c *gin.Context) {
 	stringID := c.Param("id")
 	id, err := strconv.Atoi(stringID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "The requested resource does not exist."})
+		_ = c.Error(NotFoundErr)
 		return
 	}
 
-	mission, err := h.MissionService.GetMission(id)
+	ctx := c.Request.Context()
+
+	mission, err := h.MissionService.GetMission(ctx, id)
 	if err != nil {
-		switch {
-		case errors.Is(err, NotFoundErr):
-			c.JSON(404, gin.H{"error": "The requested resource does not exist."})
-			return
-		}
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server"})
+		_ = c.Error(err)
+		return
+	}
+
+	if etag.NotModified(c, mission.Version) {
 		return
 	}
 
@@ -148,40 +151,27 @@ func (h *Handler) UpdateMission(c *gin.Context) {
 	var missionRequest UpdateMissionRequest
 	err := c.ShouldBindJSON(&missionRequest)
 	if err != nil {
-		c.JSON(400, gin.H{"error": "The request body is invalid or missing required fields"})
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
 	stringID := c.Param("id")
 	id, err := strconv.Atoi(stringID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "The requested resource does not exist."})
+		_ = c.Error(NotFoundErr)
 		return
 	}
 
 	ctx := c.Request.Context()
 
-	updatedMission, err := h.MissionService.UpdateMission(ctx, id, missionRequest)
+	updatedMission, err := h.MissionService.UpdateMission(ctx, id, missionRequest, etag.IfMatch(c))
 	if err != nil {
-		switch {
-		case errors.Is(err, CatBusyErr):
-			c.JSON(400, gin.H{"error": "The cat is already assigned to another active mission"})
-			return
-		case errors.Is(err, cat.NotFoundErr):
-			c.JSON(400, gin.H{"error": "Provided cat_id does not exist"})
-			return
-		case errors.Is(err, NotFoundErr):
-			c.JSON(404, gin.H{"error": "The requested resource does not exist."})
-			return
-		case errors.Is(err, ConflictErr):
-			c.JSON(409, gin.H{"error": "All targets must be complete before a mission can be marked as complete."})
-			return
-		}
-		fmt.Println(err)
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server"})
+		_ = c.Error(err)
 		return
 	}
 
+	etag.Set(c, updatedMission.Version)
+
 	response := UpdateMissionResponse{
 		ID:       updatedMission.ID,
 		CatID:    updatedMission.CatID,
@@ -195,24 +185,15 @@ func (h *Handler) DeleteMission(c *gin.Context) {
 	stringID := c.Param("id")
 	id, err := strconv.Atoi(stringID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "The requested resource does not exist."})
+		_ = c.Error(NotFoundErr)
 		return
 	}
 
 	ctx := c.Request.Context()
 
-	err = h.MissionService.DeleteMission(ctx, id)
+	err = h.MissionService.DeleteMission(ctx, id, etag.IfMatch(c))
 	if err != nil {
-		fmt.Println(err)
-		switch {
-		case errors.Is(err, NotFoundErr):
-			c.JSON(404, gin.H{"error": "The requested resource does not exist."})
-			return
-		case errors.Is(err, AssignedErr):
-			c.JSON(409, gin.H{"error": "Missions assigned to a cat cannot be deleted."})
-			return
-		}
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server"})
+		_ = c.Error(err)
 		return
 	}
 
@@ -223,31 +204,22 @@ func (h *Handler) AddTarget(c *gin.Context) {
 	var targetRequest TargetRequest
 	err := c.ShouldBindJSON(&targetRequest)
 	if err != nil {
-		c.JSON(400, gin.H{"error": "The request body is invalid or missing required fields"})
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
 	stringMissionID := c.Param("id")
 	missionID, err := strconv.Atoi(stringMissionID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "The requested resource does not exist."})
+		_ = c.Error(NotFoundErr)
 		return
 	}
 
-	targetID, err := h.MissionService.AddTarget(missionID, targetRequest.Name, targetRequest.Country)
+	ctx := c.Request.Context()
+
+	targetID, err := h.MissionService.AddTarget(ctx, missionID, targetRequest.Name, targetRequest.Country)
 	if err != nil {
-		switch {
-		case errors.Is(err, MaxTargetsErr):
-			c.JSON(400, gin.H{"error": "A mission cannot have more than 3 targets"})
-			return
-		case errors.Is(err, NotFoundErr):
-			c.JSON(404, gin.H{"error": "The requested resource does not exist."})
-			return
-		case errors.Is(err, ConflictErr):
-			c.JSON(409, gin.H{"error": "Mission is already complete"})
-			return
-		}
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server"})
+		_ = c.Error(err)
 		return
 	}
 
@@ -262,44 +234,38 @@ func (h *Handler) UpdateTarget(c *gin.Context) {
 	var targetRequest UpdateTargetRequest
 	err := c.ShouldBindJSON(&targetRequest)
 	if err != nil {
-		c.JSON(400, gin.H{"error": "The request body is invalid or missing required fields"})
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
 	stringMissionID := c.Param("id")
 	missionID, err := strconv.Atoi(stringMissionID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "The requested resource does not exist."})
+		_ = c.Error(NotFoundErr)
 		return
 	}
 
 	stringTargetID := c.Param("target_id")
 	targetID, err := strconv.Atoi(stringTargetID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "The requested resource does not exist."})
+		_ = c.Error(TargetNotFoundErr)
 		return
 	}
 
 	ctx := c.Request.Context()
 
-	err = h.MissionService.UpdateTarget(ctx, missionID, targetID, targetRequest.Notes, targetRequest.Complete)
+	target, err := h.MissionService.UpdateTarget(ctx, missionID, targetID, targetRequest.Notes, targetRequest.Complete, etag.IfMatch(c))
 	if err != nil {
-		switch {
-		case errors.Is(err, NotFoundErr):
-			c.JSON(404, gin.H{"error": "The requested resource does not exist."})
-			return
-		case errors.Is(err, ConflictErr):
-			c.JSON(409, gin.H{"error": "Target's mission is already complete"})
-			return
-		}
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server"})
+		_ = c.Error(err)
 		return
 	}
 
+	etag.Set(c, target.Version)
+
 	response := UpdateTargetResponse{
-		ID:       targetID,
-		Notes:    targetRequest.Notes,
-		Complete: targetRequest.Complete,
+		ID:       target.ID,
+		Notes:    target.Notes,
+		Complete: target.Complete,
 	}
 
 	c.JSON(200, response)
@@ -309,30 +275,22 @@ func (h *Handler) DeleteTarget(c *gin.Context) {
 	stringMissionID := c.Param("id")
 	missionID, err := strconv.Atoi(stringMissionID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "The requested resource does not exist."})
+		_ = c.Error(NotFoundErr)
 		return
 	}
 
 	stringTargetID := c.Param("target_id")
 	targetID, err := strconv.Atoi(stringTargetID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "The requested resource does not exist."})
+		_ = c.Error(TargetNotFoundErr)
 		return
 	}
 
 	ctx := c.Request.Context()
 
-	err = h.MissionService.DeleteTarget(ctx, missionID, targetID)
+	err = h.MissionService.DeleteTarget(ctx, missionID, targetID, etag.IfMatch(c))
 	if err != nil {
-		switch {
-		case errors.Is(err, NotFoundErr):
-			c.JSON(404, gin.H{"error": "The requested resource does not exist"})
-			return
-		case errors.Is(err, ConflictErr):
-			c.JSON(409, gin.H{"error": "Target's mission is already complete"})
-			return
-		}
-		c.JSON(500, gin.H{"error": "An unexpected error occurred on the server"})
+		_ = c.Error(err)
 		return
 	}
 
@@ -8,6 +8,11 @@ type Mission struct {
 	Complete  bool      `json:"complete"`
 	CreatedAt time.Time `json:"created_at"`
 	Targets   []Target  `json:"targets"`
+	Version   int       `json:"version"`
+}
+
+func (m *Mission) IsAssignedTo(catID int) bool {
+	return m.CatID != nil && *m.CatID == catID
 }
 
 type Target struct {
@@ -17,4 +22,5 @@ type Target struct {
 	Country   string `json:"country"`
 	Notes     string `json:"notes"`
 	Complete  bool   `json:"complete"`
+	Version   int    `json:"version"`
 }
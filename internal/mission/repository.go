@@ -5,21 +5,22 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"spy-cat-agency/internal/db"
 	"strings"
 )
 
 type Repository struct {
-	conn *sql.DB
+	conn *db.DB
 }
 
-func NewRepository(conn *sql.DB) *Repository {
+func NewRepository(conn *db.DB) *Repository {
 	return &Repository{conn: conn}
 }
 
-func (r *Repository) GetAllMissions() ([]Mission, error) {
-	query := `SELECT * FROM missions`
+func (r *Repository) GetAllMissions(ctx context.Context) ([]Mission, error) {
+	query := `SELECT id, cat_id, complete, created_at, version FROM missions`
 
-	rows, err := r.conn.Query(query)
+	rows, err := r.conn.Reader(ctx).QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +30,7 @@ func (r *Repository) GetAllMissions() ([]Mission, error) {
 
 	for rows.Next() {
 		var mission Mission
-		if err = rows.Scan(&mission.ID, &mission.CatID, &mission.Complete, &mission.CreatedAt); err != nil {
+		if err = rows.Scan(&mission.ID, &mission.CatID, &mission.Complete, &mission.CreatedAt, &mission.Version); err != nil {
 			return nil, err
 		}
 		missions = append(missions, mission)
@@ -38,57 +39,47 @@ func (r *Repository) GetAllMissions() ([]Mission, error) {
 	return missions, nil
 }
 
-func (r *Repository) CreateMission(mission *Mission) (int, error) {
-	tx, err := r.conn.Begin()
-	if err != nil {
-		return 0, err
-	}
-	defer tx.Rollback()
-
-	missionQuery := `INSERT INTO missions (complete) VALUES ($1) RETURNING id`
-	err = tx.QueryRow(missionQuery, mission.Complete).Scan(&mission.ID)
-	if err != nil {
-		return 0, err
-	}
+func (r *Repository) CreateMission(ctx context.Context, mission *Mission) (int, error) {
+	err := r.conn.InTx(ctx, func(ctx context.Context) error {
+		missionQuery := `INSERT INTO missions (complete) VALUES ($1) RETURNING id`
+		err := r.conn.QueryRowContext(ctx, missionQuery, mission.Complete).Scan(&mission.ID)
+		if err != nil {
+			return err
+		}
 
-	if len(mission.Targets) == 0 {
-		if err = tx.Commit(); err != nil {
-			return 0, err
+		if len(mission.Targets) == 0 {
+			return nil
 		}
-		return mission.ID, nil
-	}
 
-	valueStrings := make([]string, 0, len(mission.Targets))
-	valueArgs := make([]interface{}, 0, len(mission.Targets)*4)
-	i := 1
-	for _, target := range mission.Targets {
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d)", i, i+1, i+2, i+3))
-		valueArgs = append(valueArgs, mission.ID, target.Name, target.Country, target.Complete)
-		i += 4
-	}
+		valueStrings := make([]string, 0, len(mission.Targets))
+		valueArgs := make([]interface{}, 0, len(mission.Targets)*4)
+		i := 1
+		for _, target := range mission.Targets {
+			valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d)", i, i+1, i+2, i+3))
+			valueArgs = append(valueArgs, mission.ID, target.Name, target.Country, target.Complete)
+			i += 4
+		}
 
-	targetsQuery := fmt.Sprintf(
-		"INSERT INTO targets (mission_id, name, country, complete) VALUES %s",
-		strings.Join(valueStrings, ","),
-	)
+		targetsQuery := fmt.Sprintf(
+			"INSERT INTO targets (mission_id, name, country, complete) VALUES %s",
+			strings.Join(valueStrings, ","),
+		)
 
-	_, err = tx.Exec(targetsQuery, valueArgs...)
+		_, err = r.conn.ExecContext(ctx, targetsQuery, valueArgs...)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
 
-	if err = tx.Commit(); err != nil {
-		return 0, err
-	}
-
 	return mission.ID, nil
 }
 
-func (r *Repository) GetMissionByID(id int) (*Mission, error) {
+func (r *Repository) GetMissionByID(ctx context.Context, id int) (*Mission, error) {
 	query := `
 		SELECT
-			m.id, m.cat_id, m.complete, m.created_at,
-			t.id, t.mission_id, t.name, t.country, t.notes, t.complete
+			m.id, m.cat_id, m.complete, m.created_at, m.version,
+			t.id, t.mission_id, t.name, t.country, t.notes, t.complete, t.version
 		FROM
 			missions m
 		LEFT JOIN
@@ -96,7 +87,7 @@ func (r *Repository) GetMissionByID(id int) (*Mission, error) {
 		WHERE
 			m.id = $1`
 
-	rows, err := r.conn.Query(query, id)
+	rows, err := r.conn.Reader(ctx).QueryContext(ctx, query, id)
 	if err != nil {
 		return nil, err
 	}
@@ -113,14 +104,15 @@ func (r *Repository) GetMissionByID(id int) (*Mission, error) {
 		var targetCountry sql.NullString
 		var targetNotes sql.NullString
 		var targetComplete sql.NullBool
+		var targetVersion sql.NullInt64
 
 		if mission == nil {
 			mission = &Mission{}
 		}
 
 		err := rows.Scan(
-			&mission.ID, &mission.CatID, &mission.Complete, &mission.CreatedAt,
-			&targetID, &targetMissionID, &targetName, &targetCountry, &targetNotes, &targetComplete,
+			&mission.ID, &mission.CatID, &mission.Complete, &mission.CreatedAt, &mission.Version,
+			&targetID, &targetMissionID, &targetName, &targetCountry, &targetNotes, &targetComplete, &targetVersion,
 		)
 		if err != nil {
 			return nil, err
@@ -133,6 +125,7 @@ func (r *Repository) GetMissionByID(id int) (*Mission, error) {
 			target.Country = targetCountry.String
 			target.Notes = targetNotes.String
 			target.Complete = targetComplete.Bool
+			target.Version = int(targetVersion.Int64)
 			targets = append(targets, target)
 		}
 	}
@@ -146,7 +139,7 @@ func (r *Repository) GetMissionByID(id int) (*Mission, error) {
 	return mission, nil
 }
 
-func (r *Repository) UpdateMission(ctx context.Context, id int, req UpdateMissionRequest) (*Mission, error) {
+func (r *Repository) UpdateMission(ctx context.Context, id int, req UpdateMissionRequest, version *int) (*Mission, error) {
 	setValues := make([]string, 0)
 	args := make([]interface{}, 0)
 	argID := 1
@@ -167,10 +160,12 @@ func (r *Repository) UpdateMission(ctx context.Context, id int, req UpdateMissio
 		return nil, nil
 	}
 
-	args = append(args, id)
+	setValues = append(setValues, "version = version + 1")
+	args = append(args, id, version)
 
-	query := fmt.Sprintf("UPDATE missions SET %s WHERE id = $%d RETURNING id, cat_id, complete, created_at",
-		strings.Join(setValues, ", "), argID)
+	query := fmt.Sprintf(
+		"UPDATE missions SET %s WHERE id = $%d AND ($%d::integer IS NULL OR version = $%d) RETURNING id, cat_id, complete, created_at, version",
+		strings.Join(setValues, ", "), argID, argID+1, argID+1)
 
 	var mission Mission
 	err := r.conn.QueryRowContext(ctx, query, args...).Scan(
@@ -178,13 +173,14 @@ func (r *Repository) UpdateMission(ctx context.Context, id int, req UpdateMissio
 		&mission.CatID,
 		&mission.Complete,
 		&mission.CreatedAt,
+		&mission.Version,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	fullMission, err := r.GetMissionByID(mission.ID)
+	fullMission, err := r.GetMissionByID(ctx, mission.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -192,10 +188,10 @@ func (r *Repository) UpdateMission(ctx context.Context, id int, req UpdateMissio
 	return fullMission, nil
 }
 
-func (r *Repository) DeleteMission(ctx context.Context, id int) error {
-	query := `DELETE FROM missions WHERE id = $1`
+func (r *Repository) DeleteMission(ctx context.Context, id int, version *int) error {
+	query := `DELETE FROM missions WHERE id = $1 AND ($2::integer IS NULL OR version = $2)`
 
-	res, err := r.conn.ExecContext(ctx, query, id)
+	res, err := r.conn.ExecContext(ctx, query, id, version)
 	if err != nil {
 		return err
 	}
@@ -212,10 +208,17 @@ func (r *Repository) DeleteMission(ctx context.Context, id int) error {
 	return nil
 }
 
-func (r *Repository) AddTarget(target *Target) (int, error) {
-	query := `INSERT INTO targets (mission_id, name, country, complete) VALUES ($1, $2, $3, $4) RETURNING id`
+func (r *Repository) AddTarget(ctx context.Context, target *Target) (int, error) {
+	err := r.conn.InTx(ctx, func(ctx context.Context) error {
+		query := `INSERT INTO targets (mission_id, name, country, complete) VALUES ($1, $2, $3, $4) RETURNING id, version`
 
-	err := r.conn.QueryRow(query, target.MissionID, target.Name, target.Country, target.Complete).Scan(&target.ID)
+		err := r.conn.QueryRowContext(ctx, query, target.MissionID, target.Name, target.Country, target.Complete).Scan(&target.ID, &target.Version)
+		if err != nil {
+			return err
+		}
+
+		return r.bumpMissionVersion(ctx, target.MissionID)
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -223,31 +226,48 @@ func (r *Repository) AddTarget(target *Target) (int, error) {
 	return target.ID, nil
 }
 
-func (r *Repository) UpdateTarget(ctx context.Context, target *Target) error {
-	query := `UPDATE targets SET notes = $1, complete = $2 WHERE id = $3`
+func (r *Repository) UpdateTarget(ctx context.Context, target *Target, version *int) error {
+	return r.conn.InTx(ctx, func(ctx context.Context) error {
+		query := `
+			UPDATE targets
+			SET notes = $1, complete = $2, version = version + 1
+			WHERE id = $3 AND ($4::integer IS NULL OR version = $4)
+			RETURNING version`
 
-	_, err := r.conn.ExecContext(ctx, query, target.Notes, target.Complete, target.ID)
-	return err
+		err := r.conn.QueryRowContext(ctx, query, target.Notes, target.Complete, target.ID, version).Scan(&target.Version)
+		if err != nil {
+			return err
+		}
+
+		return r.bumpMissionVersion(ctx, target.MissionID)
+	})
 }
 
-func (r *Repository) DeleteTarget(ctx context.Context, id int) error {
-	query := `DELETE FROM targets WHERE id = $1`
+func (r *Repository) DeleteTarget(ctx context.Context, id int, version *int) error {
+	return r.conn.InTx(ctx, func(ctx context.Context) error {
+		query := `DELETE FROM targets WHERE id = $1 AND ($2::integer IS NULL OR version = $2) RETURNING mission_id`
 
-	res, err := r.conn.ExecContext(ctx, query, id)
-	if err != nil {
-		return err
-	}
+		var missionID int
+		err := r.conn.QueryRowContext(ctx, query, id, version).Scan(&missionID)
+		if err != nil {
+			return err
+		}
 
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
+		return r.bumpMissionVersion(ctx, missionID)
+	})
+}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
+// bumpMissionVersion changes the ETag of a mission whose targets changed, since they are part of its
+// representation.
+func (r *Repository) bumpMissionVersion(ctx context.Context, missionID int) error {
+	query := `UPDATE missions SET version = version + 1 WHERE id = $1`
 
-	return nil
+	_, err := r.conn.ExecContext(ctx, query, missionID)
+	return err
+}
+
+func (r *Repository) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.conn.InTx(ctx, fn)
 }
 
 func (r *Repository) FindActiveMissionByCatID(ctx context.Context, catID int) (*Mission, error) {
@@ -264,3 +284,24 @@ func (r *Repository) FindActiveMissionByCatID(ctx context.Context, catID int) (*
 
 	return &mission, nil
 }
+
+func (r *Repository) CountActiveMissions(ctx context.Context) (int, error) {
+	query := `SELECT COUNT(*) FROM missions WHERE cat_id IS NOT NULL AND complete = false`
+
+	var count int
+	err := r.conn.QueryRowContext(ctx, query).Scan(&count)
+	return count, err
+}
+
+func (r *Repository) CountIdleCats(ctx context.Context) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM cats c
+		WHERE NOT EXISTS (
+			SELECT 1 FROM missions m WHERE m.cat_id = c.id AND m.complete = false
+		)`
+
+	var count int
+	err := r.conn.QueryRowContext(ctx, query).Scan(&count)
+	return count, err
+}
@@ -4,39 +4,76 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"slices"
+	"spy-cat-agency/internal/audit"
+	"spy-cat-agency/internal/auth"
 	"spy-cat-agency/internal/cat"
+	"spy-cat-agency/internal/db"
+	"spy-cat-agency/internal/outbox"
+	"spy-cat-agency/internal/tracing"
 )
 
 var (
-	MaxTargetsErr = errors.New("maximum targets exceeded")
-	NotFoundErr   = errors.New("not found")
-	ConflictErr   = errors.New("conflict with current state")
-	AssignedErr   = errors.New("cannot delete an assigned mission")
-	CatBusyErr    = errors.New("cat is already assigned to an active mission")
+	MaxTargetsErr     = errors.New("a mission cannot have more than 3 targets")
+	NotFoundErr       = errors.New("mission not found")
+	TargetNotFoundErr = errors.New("target not found")
+	UnknownCatErr     = errors.New("provided cat_id does not exist")
+	ConflictErr       = errors.New("conflict with current state")
+	AssignedErr       = errors.New("cannot delete an assigned mission")
+	CatBusyErr        = errors.New("cat is already assigned to an active mission")
+
+	PreconditionFailedErr       = errors.New("mission has been modified since it was read")
+	TargetPreconditionFailedErr = errors.New("target has been modified since it was read")
 )
 
 type Service struct {
 	repo       *Repository
 	catService *cat.Service
+	audit      *audit.Service
+	outbox     *outbox.Service
 }
 
-func NewService(repo *Repository, catService *cat.Service) *Service {
+func NewService(repo *Repository, catService *cat.Service, audit *audit.Service, outbox *outbox.Service) *Service {
 	return &Service{
 		repo:       repo,
 		catService: catService,
+		audit:      audit,
+		outbox:     outbox,
 	}
 }
 
-func (s *Service) ListMissions() ([]Mission, error) {
-	missions, err := s.repo.GetAllMissions()
+func (s *Service) ListMissions(ctx context.Context) ([]Mission, error) {
+	ctx, span := tracing.Start(ctx, "mission.Service.ListMissions")
+	defer span.End()
+
+	p, err := auth.Authorize(ctx, auth.MissionsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	missions, err := s.repo.GetAllMissions(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	if p.Restricted() {
+		missions = slices.DeleteFunc(missions, func(m Mission) bool {
+			return !p.IsCat(m.CatID)
+		})
+	}
+
 	return missions, nil
 }
 
-func (s *Service) CreateMission(targetsRequest []TargetRequest) (int, error) {
+func (s *Service) CreateMission(ctx context.Context, targetsRequest []TargetRequest) (int, error) {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "mission.Service.CreateMission")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.MissionsWrite); err != nil {
+		return 0, err
+	}
+
 	var targets []Target
 	for _, t := range targetsRequest {
 		targets = append(targets, Target{
@@ -52,11 +89,40 @@ func (s *Service) CreateMission(targetsRequest []TargetRequest) (int, error) {
 		Targets:  targets,
 	}
 
-	return s.repo.CreateMission(mission)
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		id, err := s.repo.CreateMission(ctx, mission)
+		if err != nil {
+			return err
+		}
+
+		created, err := s.repo.GetMissionByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := s.audit.Record(ctx, audit.EntityMission, id, audit.ActionCreate, nil, created); err != nil {
+			return err
+		}
+
+		return s.outbox.Add(ctx, outbox.AggregateMission, id, outbox.MissionCreated, MissionCreatedEvent{MissionID: id})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return mission.ID, nil
 }
 
-func (s *Service) GetMission(id int) (*Mission, error) {
-	mission, err := s.repo.GetMissionByID(id)
+func (s *Service) GetMission(ctx context.Context, id int) (*Mission, error) {
+	ctx, span := tracing.Start(ctx, "mission.Service.GetMission")
+	defer span.End()
+
+	p, err := auth.Authorize(ctx, auth.MissionsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	mission, err := s.repo.GetMissionByID(ctx, id)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -65,86 +131,155 @@ func (s *Service) GetMission(id int) (*Mission, error) {
 		return nil, err
 	}
 
+	if p.Restricted() && !p.IsCat(mission.CatID) {
+		return nil, fmt.Errorf("%w: mission is not assigned to your cat", auth.ForbiddenErr)
+	}
+
 	return mission, nil
 }
 
-func (s *Service) UpdateMission(ctx context.Context, id int, r UpdateMissionRequest) (*Mission, error) {
-	if r.CatID == nil && r.Complete == nil {
-		return nil, nil
+func (s *Service) UpdateMission(ctx context.Context, id int, r UpdateMissionRequest, version *int) (*Mission, error) {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "mission.Service.UpdateMission")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.MissionsWrite); err != nil {
+		return nil, err
 	}
 
-	if r.CatID != nil {
-		_, err := s.catService.GetCat(*r.CatID)
+	// Nothing to change: answer with the mission as it is, still honouring If-Match.
+	if r.CatID == nil && r.Complete == nil {
+		mission, err := s.GetMission(ctx, id)
 		if err != nil {
 			return nil, err
 		}
+		if version != nil && *version != mission.Version {
+			return nil, PreconditionFailedErr
+		}
+		return mission, nil
+	}
 
-		activeMission, err := s.repo.FindActiveMissionByCatID(ctx, *r.CatID)
+	var mission *Mission
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		before, err := s.GetMission(ctx, id)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		if activeMission != nil && activeMission.ID != id {
-			return nil, CatBusyErr
+		if version != nil && *version != before.Version {
+			return PreconditionFailedErr
 		}
-	}
 
-	if r.Complete != nil && *r.Complete {
-		mission, err := s.GetMission(id)
+		if r.CatID != nil {
+			_, err := s.catService.GetCat(ctx, *r.CatID)
+			if err != nil {
+				if errors.Is(err, cat.NotFoundErr) {
+					return UnknownCatErr
+				}
+				return err
+			}
+
+			activeMission, err := s.repo.FindActiveMissionByCatID(ctx, *r.CatID)
+			if err != nil {
+				return err
+			}
+
+			if activeMission != nil && activeMission.ID != id {
+				return CatBusyErr
+			}
+		}
+
+		if r.Complete != nil && *r.Complete {
+			for _, t := range before.Targets {
+				if !t.Complete {
+					return fmt.Errorf("%w: all targets must be complete before a mission can be marked as complete", ConflictErr)
+				}
+			}
+		}
+
+		mission, err = s.repo.UpdateMission(ctx, id, r, version)
 		if err != nil {
-			switch {
-			case errors.Is(err, sql.ErrNoRows):
-				return nil, NotFoundErr
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFoundOrModified(version, NotFoundErr, PreconditionFailedErr)
 			}
-			return nil, err
+			return err
 		}
-		for _, t := range mission.Targets {
-			if !t.Complete {
-				return nil, ConflictErr
+
+		if err := s.audit.Record(ctx, audit.EntityMission, id, audit.ActionUpdate, before, mission); err != nil {
+			return err
+		}
+
+		if mission.CatID != nil && !before.IsAssignedTo(*mission.CatID) {
+			err := s.outbox.Add(ctx, outbox.AggregateMission, id, outbox.MissionAssigned, MissionAssignedEvent{
+				MissionID:     id,
+				CatID:         *mission.CatID,
+				PreviousCatID: before.CatID,
+			})
+			if err != nil {
+				return err
 			}
 		}
-	}
 
-	mission, err := s.repo.UpdateMission(ctx, id, r)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, NotFoundErr
+		if mission.Complete && !before.Complete {
+			return s.outbox.Add(ctx, outbox.AggregateMission, id, outbox.MissionCompleted, MissionCompletedEvent{
+				MissionID: id,
+				CatID:     mission.CatID,
+			})
 		}
+
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return mission, nil
 }
 
-func (s *Service) DeleteMission(ctx context.Context, id int) error {
-	mission, err := s.GetMission(id)
-	if err != nil {
+func (s *Service) DeleteMission(ctx context.Context, id int, version *int) error {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "mission.Service.DeleteMission")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.MissionsWrite); err != nil {
 		return err
 	}
 
-	if mission.CatID != nil {
-		return AssignedErr
-	}
+	return s.repo.InTx(ctx, func(ctx context.Context) error {
+		mission, err := s.GetMission(ctx, id)
+		if err != nil {
+			return err
+		}
 
-	err = s.repo.DeleteMission(ctx, id)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return NotFoundErr
+		if version != nil && *version != mission.Version {
+			return PreconditionFailedErr
+		}
+
+		if mission.CatID != nil {
+			return AssignedErr
+		}
+
+		err = s.repo.DeleteMission(ctx, id, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return notFoundOrModified(version, NotFoundErr, PreconditionFailedErr)
+			}
+			return err
+		}
+
+		if err := s.audit.Record(ctx, audit.EntityMission, id, audit.ActionDelete, mission, nil); err != nil {
+			return err
 		}
-		return err
-	}
 
-	return nil
+		return s.outbox.Add(ctx, outbox.AggregateMission, id, outbox.MissionDeleted, MissionDeletedEvent{MissionID: id})
+	})
 }
 
-func (s *Service) GetTarget(missionID, targetID int) (*Target, error) {
-	mission, err := s.repo.GetMissionByID(missionID)
+func (s *Service) GetTarget(ctx context.Context, missionID, targetID int) (*Target, error) {
+	ctx, span := tracing.Start(ctx, "mission.Service.GetTarget")
+	defer span.End()
+
+	mission, err := s.GetMission(ctx, missionID)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, NotFoundErr
-		}
 		return nil, err
 	}
 
@@ -154,20 +289,16 @@ func (s *Service) GetTarget(missionID, targetID int) (*Target, error) {
 		}
 	}
 
-	return nil, NotFoundErr
+	return nil, TargetNotFoundErr
 }
 
-func (s *Service) AddTarget(missionID int, name, country string) (int, error) {
-	mission, err := s.GetMission(missionID)
-	if err != nil {
+func (s *Service) AddTarget(ctx context.Context, missionID int, name, country string) (int, error) {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "mission.Service.AddTarget")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.MissionsWrite); err != nil {
 		return 0, err
 	}
-	if len(mission.Targets) >= 3 {
-		return 0, MaxTargetsErr
-	}
-	if mission.Complete {
-		return 0, ConflictErr
-	}
 
 	target := &Target{
 		MissionID: missionID,
@@ -177,52 +308,157 @@ func (s *Service) AddTarget(missionID int, name, country string) (int, error) {
 		Complete:  false,
 	}
 
-	return s.repo.AddTarget(target)
-}
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		mission, err := s.GetMission(ctx, missionID)
+		if err != nil {
+			return err
+		}
+		if len(mission.Targets) >= 3 {
+			return MaxTargetsErr
+		}
+		if mission.Complete {
+			return fmt.Errorf("%w: mission is already complete", ConflictErr)
+		}
 
-func (s *Service) UpdateTarget(ctx context.Context, missionID, targetID int, notes string, complete bool) error {
-	mission, err := s.GetMission(missionID)
-	if err != nil {
-		return err
-	}
+		if _, err = s.repo.AddTarget(ctx, target); err != nil {
+			return err
+		}
 
-	if mission.Complete {
-		return ConflictErr
-	}
+		if err := s.audit.Record(ctx, audit.EntityTarget, target.ID, audit.ActionCreate, nil, target); err != nil {
+			return err
+		}
 
-	target, err := s.GetTarget(missionID, targetID)
+		return s.outbox.Add(ctx, outbox.AggregateMission, missionID, outbox.TargetAdded, TargetEvent{
+			MissionID: missionID,
+			TargetID:  target.ID,
+			CatID:     mission.CatID,
+		})
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if target.Complete {
-		return ConflictErr
+	return target.ID, nil
+}
+
+func (s *Service) UpdateTarget(ctx context.Context, missionID, targetID int, notes string, complete bool, version *int) (*Target, error) {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "mission.Service.UpdateTarget")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.TargetsUpdate); err != nil {
+		return nil, err
 	}
 
-	target.Notes = notes
-	target.Complete = complete
+	var target Target
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		mission, err := s.GetMission(ctx, missionID)
+		if err != nil {
+			return err
+		}
+
+		if mission.Complete {
+			return fmt.Errorf("%w: target's mission is already complete", ConflictErr)
+		}
 
-	return s.repo.UpdateTarget(ctx, target)
-}
+		before, err := s.GetTarget(ctx, missionID, targetID)
+		if err != nil {
+			return err
+		}
+
+		if before.Complete {
+			return fmt.Errorf("%w: target is already complete", ConflictErr)
+		}
+
+		if version != nil && *version != before.Version {
+			return TargetPreconditionFailedErr
+		}
+
+		target = *before
+		target.Notes = notes
+		target.Complete = complete
 
-func (s *Service) DeleteTarget(ctx context.Context, missionID, targetID int) error {
-	target, err := s.GetTarget(missionID, targetID)
+		err = s.repo.UpdateTarget(ctx, &target, version)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFoundOrModified(version, TargetNotFoundErr, TargetPreconditionFailedErr)
+			}
+			return err
+		}
+
+		if err := s.audit.Record(ctx, audit.EntityTarget, targetID, audit.ActionUpdate, before, &target); err != nil {
+			return err
+		}
+
+		eventType := outbox.TargetUpdated
+		if target.Complete {
+			eventType = outbox.TargetCompleted
+		}
+		return s.outbox.Add(ctx, outbox.AggregateMission, missionID, eventType, TargetEvent{
+			MissionID: missionID,
+			TargetID:  targetID,
+			CatID:     mission.CatID,
+		})
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if target.Complete {
-		return ConflictErr
-	}
+	return &target, nil
+}
 
-	err = s.repo.DeleteTarget(ctx, targetID)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return NotFoundErr
-		}
+func (s *Service) DeleteTarget(ctx context.Context, missionID, targetID int, version *int) error {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "mission.Service.DeleteTarget")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.MissionsWrite); err != nil {
 		return err
 	}
 
-	return nil
+	return s.repo.InTx(ctx, func(ctx context.Context) error {
+		mission, err := s.GetMission(ctx, missionID)
+		if err != nil {
+			return err
+		}
+
+		target, err := s.GetTarget(ctx, missionID, targetID)
+		if err != nil {
+			return err
+		}
+
+		if version != nil && *version != target.Version {
+			return TargetPreconditionFailedErr
+		}
+
+		if target.Complete {
+			return fmt.Errorf("%w: target is already complete", ConflictErr)
+		}
+
+		err = s.repo.DeleteTarget(ctx, targetID, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return notFoundOrModified(version, TargetNotFoundErr, TargetPreconditionFailedErr)
+			}
+			return err
+		}
+
+		if err := s.audit.Record(ctx, audit.EntityTarget, targetID, audit.ActionDelete, target, nil); err != nil {
+			return err
+		}
+
+		return s.outbox.Add(ctx, outbox.AggregateMission, missionID, outbox.TargetDeleted, TargetEvent{
+			MissionID: missionID,
+			TargetID:  targetID,
+			CatID:     mission.CatID,
+		})
+	})
+}
+
+// notFoundOrModified explains a write that matched no row although the entity was just read: with
+// If-Match its version changed in between, otherwise it was deleted concurrently.
+func notFoundOrModified(version *int, notFound, modified error) error {
+	if version != nil {
+		return modified
+	}
+	return notFound
 }
@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/metrics"
+	"time"
+)
+
+// store is the part of Repository the dispatcher works with.
+type store interface {
+	ClaimPending(ctx context.Context, limit int) ([]Event, error)
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, nextAttemptAt time.Time, reason string) error
+	DeadLetter(ctx context.Context, id int64, reason string) error
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+	InSavepoint(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type Dispatcher struct {
+	repo      store
+	publisher Publisher
+	cfg       config.OutboxConfig
+	now       func() time.Time
+}
+
+func NewDispatcher(repo *Repository, publisher Publisher, cfg config.OutboxConfig) *Dispatcher {
+	return &Dispatcher{
+		repo:      repo,
+		publisher: publisher,
+		cfg:       cfg,
+		now:       time.Now,
+	}
+}
+
+// Run publishes pending events every poll interval until ctx is cancelled, and deletes published events
+// once they are older than the retention period.
+func (d *Dispatcher) Run(ctx context.Context) {
+	poll := time.NewTicker(d.cfg.PollInterval)
+	defer poll.Stop()
+
+	cleanup := time.NewTicker(d.cfg.CleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		d.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		case <-cleanup.C:
+			n, err := d.repo.DeletePublished(ctx, d.now().Add(-d.cfg.Retention))
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to delete published outbox events", "error", err)
+			} else if n > 0 {
+				slog.InfoContext(ctx, "Deleted published outbox events", "count", n)
+			}
+		}
+	}
+}
+
+// drain dispatches batches until one comes back short, so a backlog is not limited to one batch per poll.
+func (d *Dispatcher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := d.dispatch(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to dispatch outbox events", "error", err)
+			}
+			return
+		}
+		if n < d.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) (int, error) {
+	var claimed int
+
+	err := d.repo.InTx(ctx, func(ctx context.Context) error {
+		events, err := d.repo.ClaimPending(ctx, d.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		claimed = len(events)
+
+		for _, e := range events {
+			// Publishers may write to the database; a savepoint keeps a failing one from aborting the
+			// transaction, so the failure of this event can still be recorded and the others go out.
+			err := d.repo.InSavepoint(ctx, func(ctx context.Context) error {
+				return d.publisher.Publish(ctx, e)
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				if err := d.fail(ctx, e, err); err != nil {
+					return err
+				}
+				continue
+			}
+
+			metrics.OutboxPublishAttempts.WithLabelValues(e.Type, "success").Inc()
+			if err := d.repo.MarkPublished(ctx, e.ID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return claimed, err
+}
+
+func (d *Dispatcher) fail(ctx context.Context, e Event, cause error) error {
+	attempts := e.Attempts + 1
+
+	if attempts >= d.cfg.MaxAttempts {
+		metrics.OutboxPublishAttempts.WithLabelValues(e.Type, "dead_lettered").Inc()
+		slog.ErrorContext(ctx, "Giving up on outbox event",
+			"event_id", e.ID, "event_type", e.Type, "attempts", attempts, "error", cause)
+
+		return d.repo.DeadLetter(ctx, e.ID, cause.Error())
+	}
+
+	metrics.OutboxPublishAttempts.WithLabelValues(e.Type, "error").Inc()
+	delay := d.backoff(attempts)
+	slog.WarnContext(ctx, "Failed to publish outbox event",
+		"event_id", e.ID, "event_type", e.Type, "attempts", attempts, "retry_in", delay, "error", cause)
+
+	return d.repo.MarkFailed(ctx, e.ID, d.now().Add(delay), cause.Error())
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.RetryBackoff
+	for i := 1; i < attempts && delay < d.cfg.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxRetryBackoff)
+}
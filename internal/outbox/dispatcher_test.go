@@ -0,0 +1,217 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"spy-cat-agency/config"
+	"testing"
+	"time"
+)
+
+type storedEvent struct {
+	Event
+	published     bool
+	deadLettered  bool
+	nextAttemptAt time.Time
+	lastError     string
+}
+
+// fakeStore claims events by the rules of ClaimPending: due, neither published nor dead-lettered, and the
+// earliest such event of their aggregate.
+type fakeStore struct {
+	events []*storedEvent
+	now    time.Time
+}
+
+func newFakeStore(aggregates ...int) *fakeStore {
+	s := &fakeStore{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	for i, aggregate := range aggregates {
+		s.events = append(s.events, &storedEvent{
+			Event:         Event{ID: int64(i + 1), AggregateType: AggregateCat, AggregateID: aggregate, Type: CatHired},
+			nextAttemptAt: s.now,
+		})
+	}
+	return s
+}
+
+func (s *fakeStore) clock() time.Time {
+	return s.now
+}
+
+func (s *fakeStore) event(id int64) *storedEvent {
+	return s.events[id-1]
+}
+
+func (s *fakeStore) ClaimPending(_ context.Context, limit int) ([]Event, error) {
+	var claimed []Event
+	blocked := make(map[int]bool)
+
+	for _, e := range s.events {
+		if e.published || e.deadLettered {
+			continue
+		}
+		if !blocked[e.AggregateID] && !e.nextAttemptAt.After(s.now) && len(claimed) < limit {
+			claimed = append(claimed, e.Event)
+		}
+		blocked[e.AggregateID] = true
+	}
+
+	return claimed, nil
+}
+
+func (s *fakeStore) MarkPublished(_ context.Context, id int64) error {
+	s.event(id).published = true
+	return nil
+}
+
+func (s *fakeStore) MarkFailed(_ context.Context, id int64, nextAttemptAt time.Time, reason string) error {
+	e := s.event(id)
+	e.Attempts++
+	e.nextAttemptAt = nextAttemptAt
+	e.lastError = reason
+	return nil
+}
+
+func (s *fakeStore) DeadLetter(_ context.Context, id int64, reason string) error {
+	e := s.event(id)
+	e.Attempts++
+	e.deadLettered = true
+	e.lastError = reason
+	return nil
+}
+
+func (s *fakeStore) DeletePublished(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (s *fakeStore) InSavepoint(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// fakePublisher fails each event as often as failures says, -1 meaning always, and records what it published.
+type fakePublisher struct {
+	failures  map[int64]int
+	published []int64
+}
+
+func (p *fakePublisher) Publish(_ context.Context, e Event) error {
+	if n := p.failures[e.ID]; n != 0 {
+		p.failures[e.ID] = n - 1
+		return errors.New("partner unavailable")
+	}
+	p.published = append(p.published, e.ID)
+	return nil
+}
+
+var testConfig = config.OutboxConfig{
+	BatchSize:       10,
+	RetryBackoff:    time.Second,
+	MaxRetryBackoff: 8 * time.Second,
+	MaxAttempts:     10,
+}
+
+func newTestDispatcher(s *fakeStore, p *fakePublisher, cfg config.OutboxConfig) *Dispatcher {
+	return &Dispatcher{repo: s, publisher: p, cfg: cfg, now: s.clock}
+}
+
+// runRounds drains the outbox, moving the clock past the longest backoff between rounds, until nothing is
+// left to publish.
+func runRounds(t *testing.T, d *Dispatcher, s *fakeStore) {
+	t.Helper()
+
+	for range 20 {
+		d.drain(context.Background())
+
+		if !slices.ContainsFunc(s.events, func(e *storedEvent) bool { return !e.published && !e.deadLettered }) {
+			return
+		}
+		s.now = s.now.Add(d.cfg.MaxRetryBackoff)
+	}
+	t.Fatal("events still pending after 20 rounds")
+}
+
+func TestDispatcherOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		aggregates []int
+		failures   map[int64]int
+		want       []int64
+	}{
+		{
+			name:       "in order",
+			aggregates: []int{1, 1, 2, 1},
+			failures:   map[int64]int{},
+			want:       []int64{1, 3, 2, 4},
+		},
+		{
+			name:       "a failing event holds back its aggregate only",
+			aggregates: []int{1, 1, 2, 1},
+			failures:   map[int64]int{1: 2},
+			want:       []int64{3, 1, 2, 4},
+		},
+		{
+			name:       "a later failure",
+			aggregates: []int{1, 2, 1, 2},
+			failures:   map[int64]int{3: 1},
+			want:       []int64{1, 2, 4, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeStore(tt.aggregates...)
+			p := &fakePublisher{failures: tt.failures}
+
+			runRounds(t, newTestDispatcher(s, p, testConfig), s)
+
+			if !slices.Equal(p.published, tt.want) {
+				t.Errorf("published %v, want %v", p.published, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	s := newFakeStore(1)
+	d := newTestDispatcher(s, &fakePublisher{failures: map[int64]int{1: -1}}, testConfig)
+
+	var delays []time.Duration
+	for range 6 {
+		d.drain(context.Background())
+
+		e := s.event(1)
+		delays = append(delays, e.nextAttemptAt.Sub(s.now))
+		s.now = e.nextAttemptAt
+	}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second, 8 * time.Second}
+	if !slices.Equal(delays, want) {
+		t.Errorf("retry delays %v, want %v", delays, want)
+	}
+	if e := s.event(1); e.Attempts != 6 || e.lastError != "partner unavailable" {
+		t.Errorf("attempts = %d, last error = %q", e.Attempts, e.lastError)
+	}
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+	cfg := testConfig
+	cfg.MaxAttempts = 3
+
+	s := newFakeStore(1, 1, 2)
+	p := &fakePublisher{failures: map[int64]int{1: -1}}
+
+	runRounds(t, newTestDispatcher(s, p, cfg), s)
+
+	if e := s.event(1); !e.deadLettered || e.Attempts != 3 || e.lastError != "partner unavailable" {
+		t.Errorf("event 1: dead-lettered = %t after %d attempts, last error %q; want dead-lettered after 3",
+			e.deadLettered, e.Attempts, e.lastError)
+	}
+	if want := []int64{3, 2}; !slices.Equal(p.published, want) {
+		t.Errorf("published %v, want %v", p.published, want)
+	}
+}
@@ -0,0 +1,55 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	AggregateCat     = "cat"
+	AggregateMission = "mission"
+)
+
+const (
+	CatHired         = "cat.hired"
+	CatSalaryChanged = "cat.salary_changed"
+	CatDeleted       = "cat.deleted"
+	MissionCreated   = "mission.created"
+	MissionAssigned  = "mission.assigned"
+	MissionCompleted = "mission.completed"
+	MissionDeleted   = "mission.deleted"
+	TargetAdded      = "target.added"
+	TargetUpdated    = "target.updated"
+	TargetCompleted  = "target.completed"
+	TargetDeleted    = "target.deleted"
+)
+
+type Event struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int             `json:"aggregate_id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Attempts      int             `json:"-"`
+}
+
+// Publisher delivers events downstream. Delivery is at least once: an event may be published again if the
+// dispatcher stops before recording the success, so consumers should deduplicate by ID.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Publishers publishes every event to each of its publishers in turn, stopping at the first error. The
+// event is then retried for all of them, so they must tolerate repeats.
+type Publishers []Publisher
+
+func (p Publishers) Publish(ctx context.Context, e Event) error {
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
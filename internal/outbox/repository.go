@@ -0,0 +1,109 @@
+package outbox
+
+import (
+	"context"
+	"spy-cat-agency/internal/db"
+	"time"
+)
+
+type Repository struct {
+	conn *db.DB
+}
+
+func NewRepository(conn *db.DB) *Repository {
+	return &Repository{conn: conn}
+}
+
+func (r *Repository) CreateEvent(ctx context.Context, e *Event) error {
+	query := `
+		INSERT INTO outbox (aggregate_type, aggregate_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	return r.conn.QueryRowContext(ctx, query, e.AggregateType, e.AggregateID, e.Type, string(e.Payload)).
+		Scan(&e.ID, &e.CreatedAt)
+}
+
+// ClaimPending locks up to limit due events, at most one per aggregate: an event is only returned once every
+// earlier event of its aggregate has been published or dead-lettered. Rows locked by another dispatcher are skipped. It must
+// be called inside a transaction, which holds the locks until the outcome of each event is recorded.
+func (r *Repository) ClaimPending(ctx context.Context, limit int) ([]Event, error) {
+	query := `
+		SELECT o.id, o.aggregate_type, o.aggregate_id, o.event_type, o.payload, o.created_at, o.attempts
+		FROM outbox o
+		WHERE o.published_at IS NULL AND o.failed_at IS NULL
+			AND o.next_attempt_at <= NOW()
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox p
+				WHERE p.published_at IS NULL AND p.failed_at IS NULL
+					AND p.aggregate_type = o.aggregate_type
+					AND p.aggregate_id = o.aggregate_id
+					AND p.id < o.id
+			)
+		ORDER BY o.id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`
+
+	rows, err := r.conn.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0)
+
+	for rows.Next() {
+		var e Event
+		var payload []byte
+		err = rows.Scan(&e.ID, &e.AggregateType, &e.AggregateID, &e.Type, &payload, &e.CreatedAt, &e.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func (r *Repository) MarkPublished(ctx context.Context, id int64) error {
+	query := `UPDATE outbox SET published_at = NOW(), last_error = NULL WHERE id = $1`
+
+	_, err := r.conn.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *Repository) MarkFailed(ctx context.Context, id int64, nextAttemptAt time.Time, reason string) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3 WHERE id = $1`
+
+	_, err := r.conn.ExecContext(ctx, query, id, nextAttemptAt, reason)
+	return err
+}
+
+// DeadLetter records the last failed attempt and gives up on the event. It stays in the table for inspection
+// and no longer holds back the later events of its aggregate.
+func (r *Repository) DeadLetter(ctx context.Context, id int64, reason string) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, failed_at = NOW(), last_error = $2 WHERE id = $1`
+
+	_, err := r.conn.ExecContext(ctx, query, id, reason)
+	return err
+}
+
+func (r *Repository) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM outbox WHERE published_at < $1`
+
+	res, err := r.conn.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (r *Repository) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.conn.InTx(ctx, fn)
+}
+
+func (r *Repository) InSavepoint(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.conn.InSavepoint(ctx, fn)
+}
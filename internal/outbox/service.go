@@ -0,0 +1,31 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Add stores an event for the dispatcher. Call it with the context of the transaction that makes the change,
+// after the change's own writes: the event is then stored if and only if the change commits, and the row
+// locks taken by those writes keep the events of one aggregate in commit order.
+func (s *Service) Add(ctx context.Context, aggregateType string, aggregateID int, eventType string, payload any) error {
+	doc, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.CreateEvent(ctx, &Event{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Type:          eventType,
+		Payload:       doc,
+	})
+}
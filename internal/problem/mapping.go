@@ -0,0 +1,67 @@
+package problem
+
+import (
+	"errors"
+	"net/http"
+	"spy-cat-agency/internal/apikey"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/cat"
+	"spy-cat-agency/internal/change"
+	"spy-cat-agency/internal/mission"
+	"spy-cat-agency/internal/stream"
+	"spy-cat-agency/internal/webhook"
+	"unicode"
+	"unicode/utf8"
+)
+
+var mappings = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{auth.ForbiddenErr, http.StatusForbidden, CodeForbidden},
+	{cat.NotFoundErr, http.StatusNotFound, CodeCatNotFound},
+	{cat.WrongBreedErr, http.StatusBadRequest, CodeUnknownBreed},
+	{cat.PreconditionFailedErr, http.StatusPreconditionFailed, CodePreconditionFailed},
+	{mission.NotFoundErr, http.StatusNotFound, CodeMissionNotFound},
+	{mission.TargetNotFoundErr, http.StatusNotFound, CodeTargetNotFound},
+	{mission.UnknownCatErr, http.StatusBadRequest, CodeUnknownCat},
+	{mission.CatBusyErr, http.StatusBadRequest, CodeCatBusy},
+	{mission.MaxTargetsErr, http.StatusBadRequest, CodeMaxTargetsExceeded},
+	{mission.AssignedErr, http.StatusConflict, CodeMissionAssigned},
+	{mission.ConflictErr, http.StatusConflict, CodeConflict},
+	{mission.PreconditionFailedErr, http.StatusPreconditionFailed, CodePreconditionFailed},
+	{mission.TargetPreconditionFailedErr, http.StatusPreconditionFailed, CodePreconditionFailed},
+	{apikey.NotFoundErr, http.StatusNotFound, CodeAPIKeyNotFound},
+	{apikey.InvalidScopeErr, http.StatusBadRequest, CodeInvalidScope},
+	{webhook.NotFoundErr, http.StatusNotFound, CodeWebhookNotFound},
+	{webhook.DeliveryNotFoundErr, http.StatusNotFound, CodeWebhookDeliveryNotFound},
+	{webhook.InvalidEventErr, http.StatusBadRequest, CodeInvalidEvent},
+	{webhook.InvalidURLErr, http.StatusBadRequest, CodeInvalidWebhookURL},
+	{change.InvalidCursorErr, http.StatusBadRequest, CodeInvalidCursor},
+	{change.CursorExpiredErr, http.StatusGone, CodeCursorExpired},
+	{stream.TooManyClientsErr, http.StatusServiceUnavailable, CodeOverloaded},
+}
+
+func FromError(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			return New(m.status, m.code, sentence(err.Error()))
+		}
+	}
+
+	return New(http.StatusInternalServerError, CodeInternal, "An unexpected error occurred on the server.")
+}
+
+func sentence(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:] + "."
+}
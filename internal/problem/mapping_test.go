@@ -0,0 +1,104 @@
+package problem
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"spy-cat-agency/internal/apikey"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/cat"
+	"spy-cat-agency/internal/change"
+	"spy-cat-agency/internal/mission"
+	"spy-cat-agency/internal/stream"
+	"spy-cat-agency/internal/webhook"
+	"testing"
+)
+
+func TestFromError(t *testing.T) {
+	type test struct {
+		name   string
+		err    error
+		status int
+		code   string
+		title  string
+		detail string
+	}
+
+	tests := []test{
+		{name: "forbidden", err: auth.ForbiddenErr, status: http.StatusForbidden, code: CodeForbidden, title: "Forbidden"},
+		{name: "cat not found", err: cat.NotFoundErr, status: http.StatusNotFound, code: CodeCatNotFound, title: "Cat Not Found"},
+		{name: "unknown breed", err: cat.WrongBreedErr, status: http.StatusBadRequest, code: CodeUnknownBreed, title: "Unknown Breed"},
+		{name: "cat changed since", err: cat.PreconditionFailedErr, status: http.StatusPreconditionFailed, code: CodePreconditionFailed, title: "Precondition Failed"},
+		{name: "mission not found", err: mission.NotFoundErr, status: http.StatusNotFound, code: CodeMissionNotFound, title: "Mission Not Found"},
+		{name: "target not found", err: mission.TargetNotFoundErr, status: http.StatusNotFound, code: CodeTargetNotFound, title: "Target Not Found"},
+		{name: "unknown cat", err: mission.UnknownCatErr, status: http.StatusBadRequest, code: CodeUnknownCat, title: "Unknown Cat"},
+		{name: "cat busy", err: mission.CatBusyErr, status: http.StatusBadRequest, code: CodeCatBusy, title: "Cat Busy"},
+		{name: "too many targets", err: mission.MaxTargetsErr, status: http.StatusBadRequest, code: CodeMaxTargetsExceeded, title: "Maximum Targets Exceeded"},
+		{name: "mission assigned", err: mission.AssignedErr, status: http.StatusConflict, code: CodeMissionAssigned, title: "Mission Assigned"},
+		{name: "mission conflict", err: mission.ConflictErr, status: http.StatusConflict, code: CodeConflict, title: "Conflict"},
+		{name: "mission changed since", err: mission.PreconditionFailedErr, status: http.StatusPreconditionFailed, code: CodePreconditionFailed, title: "Precondition Failed"},
+		{name: "target changed since", err: mission.TargetPreconditionFailedErr, status: http.StatusPreconditionFailed, code: CodePreconditionFailed, title: "Precondition Failed"},
+		{name: "api key not found", err: apikey.NotFoundErr, status: http.StatusNotFound, code: CodeAPIKeyNotFound, title: "API Key Not Found"},
+		{name: "invalid scope", err: apikey.InvalidScopeErr, status: http.StatusBadRequest, code: CodeInvalidScope, title: "Invalid Scope"},
+		{name: "webhook not found", err: webhook.NotFoundErr, status: http.StatusNotFound, code: CodeWebhookNotFound, title: "Webhook Not Found"},
+		{name: "delivery not found", err: webhook.DeliveryNotFoundErr, status: http.StatusNotFound, code: CodeWebhookDeliveryNotFound, title: "Webhook Delivery Not Found"},
+		{name: "invalid event", err: webhook.InvalidEventErr, status: http.StatusBadRequest, code: CodeInvalidEvent, title: "Invalid Event"},
+		{name: "invalid webhook url", err: webhook.InvalidURLErr, status: http.StatusBadRequest, code: CodeInvalidWebhookURL, title: "Invalid Webhook URL"},
+		{
+			name:   "invalid cursor",
+			err:    change.InvalidCursorErr,
+			status: http.StatusBadRequest,
+			code:   CodeInvalidCursor,
+			title:  "Invalid Cursor",
+			detail: "Invalid change feed cursor.",
+		},
+		{
+			name:   "cursor older than the horizon",
+			err:    fmt.Errorf("listing changes: %w", change.CursorExpiredErr),
+			status: http.StatusGone,
+			code:   CodeCursorExpired,
+			title:  "Cursor Expired",
+			detail: "Listing changes: cursor is older than the retained changes, resync and start from a new cursor.",
+		},
+		{name: "too many stream clients", err: stream.TooManyClientsErr, status: http.StatusServiceUnavailable, code: CodeOverloaded, title: "Service Overloaded"},
+		{
+			name:   "a problem is passed through",
+			err:    fmt.Errorf("binding: %w", New(http.StatusBadRequest, CodeInvalidRequest, "Malformed JSON.")),
+			status: http.StatusBadRequest,
+			code:   CodeInvalidRequest,
+			title:  "Invalid Request",
+			detail: "Malformed JSON.",
+		},
+		{
+			name:   "unmapped",
+			err:    fmt.Errorf("scanning cat 3: %w", sql.ErrConnDone),
+			status: http.StatusInternalServerError,
+			code:   CodeInternal,
+			title:  "Internal Server Error",
+			detail: "An unexpected error occurred on the server.",
+		},
+	}
+
+	for _, m := range mappings {
+		if !slices.ContainsFunc(tests, func(tt test) bool { return errors.Is(tt.err, m.err) }) {
+			t.Errorf("no test case for %q", m.err)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := FromError(tt.err)
+			if p.Status != tt.status || p.Code != tt.code || p.Title != tt.title {
+				t.Errorf("FromError() = %d %s %q, want %d %s %q", p.Status, p.Code, p.Title, tt.status, tt.code, tt.title)
+			}
+			if p.Type != "urn:spy-cat-agency:problem:"+tt.code {
+				t.Errorf("type = %q", p.Type)
+			}
+			if tt.detail != "" && p.Detail != tt.detail {
+				t.Errorf("detail = %q, want %q", p.Detail, tt.detail)
+			}
+		})
+	}
+}
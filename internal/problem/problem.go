@@ -0,0 +1,113 @@
+package problem
+
+import (
+	"github.com/gin-gonic/gin"
+	"spy-cat-agency/internal/requestid"
+)
+
+const ContentType = "application/problem+json"
+
+const (
+	CodeValidationFailed        = "validation_failed"
+	CodeInvalidRequest          = "invalid_request"
+	CodeRouteNotFound           = "route_not_found"
+	CodeUnauthenticated         = "unauthenticated"
+	CodeForbidden               = "forbidden"
+	CodeCatNotFound             = "cat_not_found"
+	CodeUnknownBreed            = "unknown_breed"
+	CodeMissionNotFound         = "mission_not_found"
+	CodeTargetNotFound          = "target_not_found"
+	CodeUnknownCat              = "unknown_cat"
+	CodeCatBusy                 = "cat_busy"
+	CodeMaxTargetsExceeded      = "max_targets_exceeded"
+	CodeMissionAssigned         = "mission_assigned"
+	CodeConflict                = "conflict"
+	CodePreconditionFailed      = "precondition_failed"
+	CodeAPIKeyNotFound          = "api_key_not_found"
+	CodeInvalidScope            = "invalid_scope"
+	CodeWebhookNotFound         = "webhook_not_found"
+	CodeWebhookDeliveryNotFound = "webhook_delivery_not_found"
+	CodeInvalidEvent            = "invalid_event"
+	CodeInvalidWebhookURL       = "invalid_webhook_url"
+	CodeInvalidCursor           = "invalid_cursor"
+	CodeCursorExpired           = "cursor_expired"
+	CodeIdempotencyKeyReused    = "idempotency_key_reused"
+	CodeIdempotencyInProgress   = "idempotency_in_progress"
+	CodeRateLimited             = "rate_limited"
+	CodeOverloaded              = "overloaded"
+	CodeInternal                = "internal_error"
+)
+
+var titles = map[string]string{
+	CodeValidationFailed:        "Validation Failed",
+	CodeInvalidRequest:          "Invalid Request",
+	CodeRouteNotFound:           "Route Not Found",
+	CodeUnauthenticated:         "Unauthenticated",
+	CodeForbidden:               "Forbidden",
+	CodeCatNotFound:             "Cat Not Found",
+	CodeUnknownBreed:            "Unknown Breed",
+	CodeMissionNotFound:         "Mission Not Found",
+	CodeTargetNotFound:          "Target Not Found",
+	CodeUnknownCat:              "Unknown Cat",
+	CodeCatBusy:                 "Cat Busy",
+	CodeMaxTargetsExceeded:      "Maximum Targets Exceeded",
+	CodeMissionAssigned:         "Mission Assigned",
+	CodeConflict:                "Conflict",
+	CodePreconditionFailed:      "Precondition Failed",
+	CodeAPIKeyNotFound:          "API Key Not Found",
+	CodeInvalidScope:            "Invalid Scope",
+	CodeWebhookNotFound:         "Webhook Not Found",
+	CodeWebhookDeliveryNotFound: "Webhook Delivery Not Found",
+	CodeInvalidEvent:            "Invalid Event",
+	CodeInvalidWebhookURL:       "Invalid Webhook URL",
+	CodeInvalidCursor:           "Invalid Cursor",
+	CodeCursorExpired:           "Cursor Expired",
+	CodeIdempotencyKeyReused:    "Idempotency Key Reused",
+	CodeIdempotencyInProgress:   "Idempotent Request In Progress",
+	CodeRateLimited:             "Too Many Requests",
+	CodeOverloaded:              "Service Overloaded",
+	CodeInternal:                "Internal Server Error",
+}
+
+type Violation struct {
+	In      string `json:"in"`
+	Pointer string `json:"pointer"`
+	Reason  string `json:"reason"`
+}
+
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"`
+	Code      string      `json:"code"`
+	RequestID string      `json:"request_id,omitempty"`
+	Errors    []Violation `json:"errors,omitempty"`
+}
+
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "urn:spy-cat-agency:problem:" + code,
+		Title:  titles[code],
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func (p *Problem) Error() string {
+	return p.Code + ": " + p.Detail
+}
+
+func Write(c *gin.Context, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = requestid.FromContext(c.Request.Context())
+	}
+
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// Limiter keeps one token bucket per key. Buckets refill continuously at Limit.Rate tokens per second up to
+// Limit.Burst, and full buckets are forgotten so that idle clients do not accumulate in memory.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func New() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket), lastSweep: time.Now(), now: time.Now}
+}
+
+// Allow takes a token from the bucket of key if it has one.
+func (l *Limiter) Allow(key string, limit Limit) Result {
+	return l.take(key, limit, true)
+}
+
+// Peek reports whether the bucket of key has a token, without taking it.
+func (l *Limiter) Peek(key string, limit Limit) Result {
+	return l.take(key, limit, false)
+}
+
+func (l *Limiter) take(key string, limit Limit, consume bool) Result {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: float64(limit.Burst), last: now, limit: limit}
+		l.buckets[key] = b
+	}
+
+	b.refill(now)
+
+	result := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		if consume {
+			b.tokens--
+		}
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+
+	return result
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	b.last = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func newTestLimiter() (*Limiter, *clock) {
+	c := &clock{t: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	l := New()
+	l.now = c.now
+	l.lastSweep = c.t
+	return l, c
+}
+
+func TestLimiter(t *testing.T) {
+	type step struct {
+		advance time.Duration
+		peek    bool
+		want    Result
+	}
+
+	tests := []struct {
+		name  string
+		limit Limit
+		steps []step
+	}{
+		{
+			name:  "consumes the burst",
+			limit: Limit{Rate: 1, Burst: 2},
+			steps: []step{
+				{want: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+				{want: Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}},
+				{want: Result{Limit: 2, Remaining: 0, Reset: 2 * time.Second, RetryAfter: time.Second}},
+			},
+		},
+		{
+			name:  "refills at the rate",
+			limit: Limit{Rate: 1, Burst: 2},
+			steps: []step{
+				{want: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+				{want: Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}},
+				{advance: time.Second, want: Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}},
+			},
+		},
+		{
+			name:  "rounds partial tokens up to whole seconds",
+			limit: Limit{Rate: 2, Burst: 1},
+			steps: []step{
+				{want: Result{Allowed: true, Limit: 1, Remaining: 0, Reset: time.Second}},
+				{advance: 250 * time.Millisecond, want: Result{Limit: 1, Remaining: 0, Reset: time.Second, RetryAfter: time.Second}},
+				{advance: 250 * time.Millisecond, want: Result{Allowed: true, Limit: 1, Remaining: 0, Reset: time.Second}},
+			},
+		},
+		{
+			name:  "refills no further than the burst",
+			limit: Limit{Rate: 1, Burst: 2},
+			steps: []step{
+				{want: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+				{advance: 10 * time.Second, want: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+			},
+		},
+		{
+			name:  "peek does not consume",
+			limit: Limit{Rate: 1, Burst: 1},
+			steps: []step{
+				{peek: true, want: Result{Allowed: true, Limit: 1, Remaining: 1}},
+				{peek: true, want: Result{Allowed: true, Limit: 1, Remaining: 1}},
+				{want: Result{Allowed: true, Limit: 1, Remaining: 0, Reset: time.Second}},
+				{peek: true, want: Result{Limit: 1, Remaining: 0, Reset: time.Second, RetryAfter: time.Second}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, c := newTestLimiter()
+
+			for i, s := range tt.steps {
+				c.t = c.t.Add(s.advance)
+
+				var got Result
+				if s.peek {
+					got = l.Peek("client", tt.limit)
+				} else {
+					got = l.Allow("client", tt.limit)
+				}
+
+				if got != s.want {
+					t.Errorf("step %d: got %+v, want %+v", i, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterKeys(t *testing.T) {
+	l, _ := newTestLimiter()
+	limit := Limit{Rate: 1, Burst: 1}
+
+	if !l.Allow("a", limit).Allowed {
+		t.Fatal("first request of a was rejected")
+	}
+	if l.Allow("a", limit).Allowed {
+		t.Error("second request of a was allowed")
+	}
+	if !l.Allow("b", limit).Allowed {
+		t.Error("b was limited by the bucket of a")
+	}
+	if !l.Allow("a", Limit{Rate: 1, Burst: 5}).Allowed {
+		t.Error("a new limit for a did not start a new bucket")
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l, c := newTestLimiter()
+	limit := Limit{Rate: 1, Burst: 10}
+
+	l.Allow("idle", limit)
+	c.t = c.t.Add(2 * sweepInterval)
+	l.Allow("busy", limit)
+
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("the refilled bucket of an idle key was kept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("the bucket of a busy key was dropped")
+	}
+}
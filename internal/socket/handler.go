@@ -0,0 +1,111 @@
+package socket
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"log/slog"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/middleware"
+	"spy-cat-agency/internal/mission"
+	"spy-cat-agency/internal/outbox"
+	"spy-cat-agency/internal/problem"
+	"spy-cat-agency/internal/stream"
+	"sync"
+)
+
+// Message is sent by the client: subscribe, update_target or ack.
+type Message struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+
+	MissionID   *int   `json:"mission_id"`
+	CatID       *int   `json:"cat_id"`
+	LastEventID *int64 `json:"last_event_id"`
+
+	TargetID *int    `json:"target_id"`
+	Notes    *string `json:"notes"`
+	Complete *bool   `json:"complete"`
+	Version  *int    `json:"version"`
+
+	EventID *int64 `json:"event_id"`
+}
+
+// Reply is sent by the server: the result of a request, an error, or an event.
+type Reply struct {
+	Type   string           `json:"type"`
+	ID     string           `json:"id,omitempty"`
+	Reset  bool             `json:"reset,omitempty"`
+	Target *TargetResponse  `json:"target,omitempty"`
+	Event  *outbox.Event    `json:"event,omitempty"`
+	Error  *problem.Problem `json:"error,omitempty"`
+}
+
+type TargetResponse struct {
+	ID       int    `json:"id"`
+	Notes    string `json:"notes"`
+	Complete bool   `json:"complete"`
+	Version  int    `json:"version"`
+}
+
+// KeyChecker tells whether an API key may still be used. *apikey.Service looks it up in the database.
+type KeyChecker interface {
+	Check(ctx context.Context, id int) error
+}
+
+type Handler struct {
+	MissionService *mission.Service
+	Broker         *stream.Broker
+	Keys           KeyChecker
+	RateLimiter    *middleware.RateLimiter
+
+	cfg      config.WebSocketConfig
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	sessions map[*session]struct{}
+}
+
+func NewHandler(missionService *mission.Service, broker *stream.Broker, keys KeyChecker,
+	rateLimiter *middleware.RateLimiter, cfg config.WebSocketConfig) *Handler {
+	return &Handler{
+		MissionService: missionService,
+		Broker:         broker,
+		Keys:           keys,
+		RateLimiter:    rateLimiter,
+		cfg:            cfg,
+		sessions:       make(map[*session]struct{}),
+	}
+}
+
+func (h *Handler) Connect(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already answered with an HTTP error.
+		slog.DebugContext(c.Request.Context(), "WebSocket upgrade failed", "error", err)
+		return
+	}
+
+	s := newSession(h, conn, auth.FromContext(c.Request.Context()))
+
+	h.mu.Lock()
+	h.sessions[s] = struct{}{}
+	h.mu.Unlock()
+
+	s.run(c.Request.Context())
+
+	h.mu.Lock()
+	delete(h.sessions, s)
+	h.mu.Unlock()
+}
+
+// Close asks every open connection to go away, so that the server can shut down.
+func (h *Handler) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.sessions {
+		s.close(websocket.CloseGoingAway, "server is shutting down")
+	}
+}
@@ -0,0 +1,320 @@
+package socket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"log/slog"
+	"net/http"
+	"spy-cat-agency/internal/apikey"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/outbox"
+	"spy-cat-agency/internal/problem"
+	"spy-cat-agency/internal/stream"
+	"sync"
+	"time"
+)
+
+// subscription hands a new stream subscription to the writer together with the reply that confirms it,
+// so that the reply precedes the replayed events.
+type subscription struct {
+	sub   *stream.Subscription
+	reply Reply
+}
+
+// session is one connection. The read loop handles requests in order; a single writer goroutine owns all
+// writes.
+type session struct {
+	h         *Handler
+	conn      *websocket.Conn
+	principal *auth.Principal
+
+	replies chan Reply
+	subs    chan subscription
+	// done stops the writer once the read loop ends; stopped tells the read loop the writer is gone.
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu      sync.Mutex
+	unacked []int64
+	acked   *int64
+}
+
+func newSession(h *Handler, conn *websocket.Conn, principal *auth.Principal) *session {
+	return &session{
+		h:         h,
+		conn:      conn,
+		principal: principal,
+		replies:   make(chan Reply, 16),
+		subs:      make(chan subscription),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+func (s *session) run(ctx context.Context) {
+	defer s.conn.Close()
+
+	var writer sync.WaitGroup
+	writer.Add(1)
+	go func() {
+		defer writer.Done()
+		s.write(ctx)
+	}()
+
+	s.read(ctx)
+
+	close(s.done)
+	writer.Wait()
+}
+
+func (s *session) read(ctx context.Context) {
+	pongWait := 2 * s.h.cfg.PingInterval
+
+	s.conn.SetReadLimit(int64(s.h.cfg.MaxMessageBytes))
+	_ = s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var m Message
+		if err = json.Unmarshal(data, &m); err != nil {
+			s.reply(Reply{Type: "error", Error: invalid("The message is not a valid JSON object.")})
+			continue
+		}
+
+		switch m.Type {
+		case "subscribe":
+			if s.allow(m) {
+				s.subscribe(ctx, m)
+			}
+		case "update_target":
+			if s.allow(m) {
+				s.updateTarget(ctx, m)
+			}
+		case "ack":
+			s.ack(m)
+		default:
+			s.reply(Reply{Type: "error", ID: m.ID, Error: invalid("Unknown message type; expected subscribe, update_target or ack.")})
+		}
+	}
+}
+
+// allow charges a request to the client's rate limit like an HTTP request, answering it with an error when
+// the budget is spent. Acks are free: they only answer what the server sent.
+func (s *session) allow(m Message) bool {
+	result, _ := s.h.RateLimiter.Allow(s.principal.Subject, "ws "+m.Type)
+	if result.Allowed {
+		return true
+	}
+
+	s.reply(Reply{Type: "error", ID: m.ID, Error: problem.New(http.StatusTooManyRequests, problem.CodeRateLimited,
+		fmt.Sprintf("Too many messages; retry in %d seconds.", int(result.RetryAfter.Seconds())))})
+	return false
+}
+
+func (s *session) subscribe(ctx context.Context, m Message) {
+	lastEventID := m.LastEventID
+	if lastEventID == nil {
+		s.mu.Lock()
+		lastEventID = s.acked
+		s.mu.Unlock()
+	}
+
+	sub, err := s.h.Broker.Subscribe(ctx, stream.Filter{MissionID: m.MissionID, CatID: m.CatID}, lastEventID)
+	if err != nil {
+		s.reply(Reply{Type: "error", ID: m.ID, Error: s.problem(ctx, err)})
+		return
+	}
+
+	select {
+	case s.subs <- subscription{sub: sub, reply: Reply{Type: "result", ID: m.ID, Reset: sub.Missed}}:
+	case <-s.stopped:
+		s.h.Broker.Unsubscribe(sub)
+	}
+}
+
+func (s *session) updateTarget(ctx context.Context, m Message) {
+	if m.MissionID == nil || m.TargetID == nil || m.Notes == nil || m.Complete == nil {
+		s.reply(Reply{Type: "error", ID: m.ID, Error: invalid("mission_id, target_id, notes and complete are required.")})
+		return
+	}
+
+	release, ok := s.h.RateLimiter.Acquire()
+	defer release()
+	if !ok {
+		s.reply(Reply{Type: "error", ID: m.ID, Error: problem.New(http.StatusServiceUnavailable, problem.CodeOverloaded,
+			"The server is handling too many requests; retry later.")})
+		return
+	}
+
+	target, err := s.h.MissionService.UpdateTarget(ctx, *m.MissionID, *m.TargetID, *m.Notes, *m.Complete, m.Version)
+	if err != nil {
+		s.reply(Reply{Type: "error", ID: m.ID, Error: s.problem(ctx, err)})
+		return
+	}
+
+	s.reply(Reply{Type: "result", ID: m.ID, Target: &TargetResponse{
+		ID:       target.ID,
+		Notes:    target.Notes,
+		Complete: target.Complete,
+		Version:  target.Version,
+	}})
+}
+
+// ack acknowledges an event and every event sent before it.
+func (s *session) ack(m Message) {
+	if m.EventID == nil {
+		s.reply(Reply{Type: "error", ID: m.ID, Error: invalid("event_id is required.")})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, id := range s.unacked {
+		if id == *m.EventID {
+			s.unacked = s.unacked[i+1:]
+			s.acked = m.EventID
+			return
+		}
+	}
+}
+
+func (s *session) reply(r Reply) {
+	select {
+	case s.replies <- r:
+	case <-s.stopped:
+	}
+}
+
+func (s *session) write(ctx context.Context) {
+	defer close(s.stopped)
+
+	ping := time.NewTicker(s.h.cfg.PingInterval)
+	defer ping.Stop()
+
+	var current *stream.Subscription
+	defer func() {
+		if current != nil {
+			s.h.Broker.Unsubscribe(current)
+		}
+	}()
+
+	// A nil channel blocks, so no events are read until the client subscribes.
+	var events <-chan outbox.Event
+
+	// The credentials were checked once, on the handshake: the connection ends when they expire, and API keys
+	// are checked again every auth_check_interval in case they are revoked.
+	var expired, recheck <-chan time.Time
+	if s.principal.ExpiresAt != nil {
+		timer := time.NewTimer(time.Until(*s.principal.ExpiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	if s.principal.APIKeyID != nil {
+		ticker := time.NewTicker(s.h.cfg.AuthCheckInterval)
+		defer ticker.Stop()
+		recheck = ticker.C
+	}
+
+	for {
+		var err error
+
+		select {
+		case <-s.done:
+			return
+		case r := <-s.replies:
+			err = s.send(r)
+		case next := <-s.subs:
+			if current != nil {
+				s.h.Broker.Unsubscribe(current)
+			}
+			current, events = next.sub, next.sub.Events()
+
+			err = s.send(next.reply)
+			for _, e := range next.sub.Replay {
+				if err == nil {
+					err = s.sendEvent(e)
+				}
+			}
+		case e, ok := <-events:
+			if !ok {
+				// The broker let go of the subscription: the client fell behind or events were missed. It
+				// reconnects and resumes from its last ack.
+				s.close(websocket.CloseTryAgainLater, "resubscribe to resume")
+				return
+			}
+			err = s.sendEvent(e)
+		case <-ping.C:
+			err = s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.h.cfg.WriteTimeout))
+		case <-expired:
+			s.close(websocket.ClosePolicyViolation, "credentials expired")
+			return
+		case <-recheck:
+			checkErr := s.h.Keys.Check(ctx, *s.principal.APIKeyID)
+			if errors.Is(checkErr, apikey.InvalidKeyErr) {
+				s.close(websocket.ClosePolicyViolation, "api key is no longer valid")
+				return
+			}
+			if checkErr != nil {
+				slog.WarnContext(ctx, "Failed to check the API key of a WebSocket", "error", checkErr)
+			}
+		}
+
+		if err != nil {
+			if !errors.Is(err, errTooManyUnacked) {
+				slog.DebugContext(ctx, "WebSocket write failed", "error", err)
+			}
+			_ = s.conn.Close()
+			return
+		}
+	}
+}
+
+var errTooManyUnacked = errors.New("too many unacknowledged events")
+
+func (s *session) sendEvent(e outbox.Event) error {
+	s.mu.Lock()
+	if len(s.unacked) >= s.h.cfg.MaxUnacked {
+		s.mu.Unlock()
+		s.close(websocket.ClosePolicyViolation, "too many unacknowledged events")
+		return errTooManyUnacked
+	}
+	s.unacked = append(s.unacked, e.ID)
+	s.mu.Unlock()
+
+	return s.send(Reply{Type: "event", Event: &e})
+}
+
+func (s *session) send(r Reply) error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.h.cfg.WriteTimeout))
+	return s.conn.WriteJSON(r)
+}
+
+// close sends a close frame; the read loop then ends when the connection goes away.
+func (s *session) close(code int, reason string) {
+	_ = s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(s.h.cfg.WriteTimeout))
+	_ = s.conn.Close()
+}
+
+func (s *session) problem(ctx context.Context, err error) *problem.Problem {
+	p := problem.FromError(err)
+	if p.Status == http.StatusInternalServerError {
+		slog.ErrorContext(ctx, "WebSocket request failed", "error", err)
+	}
+	return p
+}
+
+func invalid(detail string) *problem.Problem {
+	return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, detail)
+}
@@ -0,0 +1,245 @@
+package socket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/apikey"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/middleware"
+	"spy-cat-agency/internal/outbox"
+	"spy-cat-agency/internal/problem"
+	"spy-cat-agency/internal/stream"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testConfig = config.WebSocketConfig{
+	MaxUnacked:        2,
+	MaxMessageBytes:   4096,
+	PingInterval:      time.Minute,
+	WriteTimeout:      time.Second,
+	AuthCheckInterval: 20 * time.Millisecond,
+}
+
+type fakeKeys struct {
+	revoked atomic.Bool
+}
+
+func (k *fakeKeys) Check(context.Context, int) error {
+	if k.revoked.Load() {
+		return apikey.InvalidKeyErr
+	}
+	return nil
+}
+
+type testServer struct {
+	broker *stream.Broker
+	keys   *fakeKeys
+	url    string
+}
+
+// newTestServer serves the WebSocket to clients authenticated as principal, with rate limits of rateLimit.
+func newTestServer(t *testing.T, principal *auth.Principal, rateLimit config.RateLimitConfig) *testServer {
+	t.Helper()
+
+	ts := &testServer{
+		broker: stream.NewBroker(config.StreamConfig{MaxClients: 10, BufferSize: 10, ClientBuffer: 10}),
+		keys:   &fakeKeys{},
+	}
+	h := NewHandler(nil, ts.broker, ts.keys, middleware.NewRateLimiter(rateLimit), testConfig)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), principal))
+	}, h.Connect)
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(func() {
+		h.Close()
+		srv.Close()
+	})
+
+	ts.url = "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+	return ts
+}
+
+func (ts *testServer) dial(t *testing.T) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(ts.url, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func testEvent(id int64) outbox.Event {
+	return outbox.Event{ID: id, AggregateType: outbox.AggregateMission, AggregateID: 1, Type: outbox.TargetUpdated,
+		Payload: json.RawMessage(`{"mission_id":1,"target_id":1,"cat_id":1}`)}
+}
+
+func send(t *testing.T, conn *websocket.Conn, m map[string]any) {
+	t.Helper()
+
+	if err := conn.WriteJSON(m); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+}
+
+// receive reads the next reply, or returns the close error that ended the connection.
+func receive(t *testing.T, conn *websocket.Conn) (Reply, error) {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var r Reply
+	err := conn.ReadJSON(&r)
+	return r, err
+}
+
+func expectClose(t *testing.T, conn *websocket.Conn, code int, text string) {
+	t.Helper()
+
+	for {
+		_, err := receive(t, conn)
+		if err == nil {
+			continue
+		}
+
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != code || closeErr.Text != text {
+			t.Fatalf("connection ended with %v, want close %d %q", err, code, text)
+		}
+		return
+	}
+}
+
+func subscribe(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+
+	send(t, conn, map[string]any{"type": "subscribe", "id": "sub"})
+	if r, err := receive(t, conn); err != nil || r.Type != "result" || r.ID != "sub" {
+		t.Fatalf("subscribe: got %+v, %v", r, err)
+	}
+}
+
+var handler = &auth.Principal{Subject: "user:1", Role: auth.RoleHandler}
+
+var unlimited = config.RateLimitConfig{}
+
+func TestAckWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		ack    bool
+		closed bool
+	}{
+		{"acknowledged events keep the connection open", true, false},
+		{"too many unacknowledged events close it", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, handler, unlimited)
+			conn := ts.dial(t)
+			subscribe(t, conn)
+
+			for id := int64(1); id <= 2; id++ {
+				ts.broker.Broadcast(testEvent(id))
+				r, err := receive(t, conn)
+				if err != nil || r.Type != "event" || r.Event.ID != id {
+					t.Fatalf("event %d: got %+v, %v", id, r, err)
+				}
+				if tt.ack {
+					send(t, conn, map[string]any{"type": "ack", "event_id": id})
+				}
+			}
+
+			// Acks are handled in order with the other messages; a subscribe round trip makes sure they are in.
+			subscribe(t, conn)
+
+			ts.broker.Broadcast(testEvent(3))
+			if tt.closed {
+				expectClose(t, conn, websocket.ClosePolicyViolation, "too many unacknowledged events")
+				return
+			}
+			if r, err := receive(t, conn); err != nil || r.Type != "event" || r.Event.ID != 3 {
+				t.Fatalf("event 3: got %+v, %v", r, err)
+			}
+		})
+	}
+}
+
+func TestMessageRateLimit(t *testing.T) {
+	ts := newTestServer(t, handler, config.RateLimitConfig{Enabled: true, Rate: 0.01, Burst: 1})
+	conn := ts.dial(t)
+
+	subscribe(t, conn)
+
+	send(t, conn, map[string]any{"type": "subscribe", "id": "again"})
+	r, err := receive(t, conn)
+	if err != nil || r.Type != "error" || r.ID != "again" || r.Error == nil ||
+		r.Error.Status != http.StatusTooManyRequests || r.Error.Code != problem.CodeRateLimited {
+		t.Fatalf("second subscribe: got %+v, %v; want a rate_limited error", r, err)
+	}
+
+	// Acks are not charged.
+	send(t, conn, map[string]any{"type": "ack", "id": "ack"})
+	if r, err := receive(t, conn); err != nil || r.Error == nil || r.Error.Code != problem.CodeInvalidRequest {
+		t.Fatalf("ack: got %+v, %v; want an invalid_request error", r, err)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	keyID := 5
+
+	tests := []struct {
+		name      string
+		principal func() *auth.Principal
+		revoke    bool
+		text      string
+	}{
+		{
+			name: "token expires",
+			principal: func() *auth.Principal {
+				expiresAt := time.Now().Add(100 * time.Millisecond)
+				return &auth.Principal{Subject: "user:1", Role: auth.RoleHandler, ExpiresAt: &expiresAt}
+			},
+			text: "credentials expired",
+		},
+		{
+			name: "api key revoked",
+			principal: func() *auth.Principal {
+				return &auth.Principal{Subject: "api_key:5", Role: auth.RoleHandler, APIKeyID: &keyID}
+			},
+			revoke: true,
+			text:   "api key is no longer valid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, tt.principal(), unlimited)
+			conn := ts.dial(t)
+			subscribe(t, conn)
+
+			if tt.revoke {
+				// A few checks pass while the key is valid.
+				time.Sleep(3 * testConfig.AuthCheckInterval)
+				subscribe(t, conn)
+				ts.keys.revoked.Store(true)
+			}
+
+			expectClose(t, conn, websocket.ClosePolicyViolation, tt.text)
+		})
+	}
+}
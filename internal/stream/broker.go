@@ -0,0 +1,220 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/metrics"
+	"spy-cat-agency/internal/outbox"
+	"sync"
+)
+
+var TooManyClientsErr = errors.New("too many open event streams")
+
+type Filter struct {
+	MissionID *int
+	CatID     *int
+}
+
+type event struct {
+	outbox.Event
+	catID         *int
+	previousCatID *int
+}
+
+// matches takes an event to a cat's filter when the mission is, or until this event was, assigned to the cat.
+func (f Filter) matches(e event) bool {
+	if f.MissionID != nil && e.AggregateID != *f.MissionID {
+		return false
+	}
+	if f.CatID != nil && !sameCat(e.catID, *f.CatID) && !sameCat(e.previousCatID, *f.CatID) {
+		return false
+	}
+	return true
+}
+
+func sameCat(id *int, catID int) bool {
+	return id != nil && *id == catID
+}
+
+type Subscription struct {
+	// Replay holds the buffered events after the requested Last-Event-ID.
+	Replay []outbox.Event
+	// Missed is set when the Last-Event-ID is no longer buffered, so events may have been lost and the
+	// client should reload its state. Latest is then the ID to resume from, or 0 if nothing is buffered.
+	Missed bool
+	Latest int64
+
+	events chan outbox.Event
+	filter Filter
+}
+
+func (s *Subscription) Events() <-chan outbox.Event {
+	return s.events
+}
+
+// Broker fans mission events out to the open streams and keeps the most recent ones for clients that
+// reconnect.
+type Broker struct {
+	cfg config.StreamConfig
+
+	mu      sync.Mutex
+	buffer  []event
+	ids     map[int64]struct{}
+	clients map[*Subscription]struct{}
+	closed  bool
+}
+
+func NewBroker(cfg config.StreamConfig) *Broker {
+	return &Broker{
+		cfg:     cfg,
+		ids:     make(map[int64]struct{}),
+		clients: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe opens a stream of the mission events matching filter. Field agents are limited to the missions
+// of their own cat.
+func (b *Broker) Subscribe(ctx context.Context, filter Filter, lastEventID *int64) (*Subscription, error) {
+	p, err := auth.Authorize(ctx, auth.MissionsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Restricted() {
+		if p.CatID == nil || filter.CatID != nil && *filter.CatID != *p.CatID {
+			return nil, fmt.Errorf("%w: only your cat's missions can be followed", auth.ForbiddenErr)
+		}
+		filter.CatID = p.CatID
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.clients) >= b.cfg.MaxClients {
+		return nil, TooManyClientsErr
+	}
+
+	s := &Subscription{
+		events: make(chan outbox.Event, b.cfg.ClientBuffer),
+		filter: filter,
+	}
+
+	if b.closed {
+		close(s.events)
+		return s, nil
+	}
+
+	if lastEventID != nil {
+		s.Replay, s.Missed = b.replay(*lastEventID, filter)
+		if s.Missed && len(b.buffer) > 0 {
+			s.Latest = b.buffer[len(b.buffer)-1].ID
+		}
+	}
+
+	b.clients[s] = struct{}{}
+	metrics.StreamClients.Inc()
+
+	return s, nil
+}
+
+func (b *Broker) replay(lastEventID int64, filter Filter) ([]outbox.Event, bool) {
+	if _, ok := b.ids[lastEventID]; !ok {
+		return nil, true
+	}
+
+	var events []outbox.Event
+	found := false
+	for _, e := range b.buffer {
+		if found && filter.matches(e) {
+			events = append(events, e.Event)
+		}
+		found = found || e.ID == lastEventID
+	}
+
+	return events, false
+}
+
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.drop(s)
+}
+
+// Broadcast passes a published event on to the matching streams. A client that does not keep up is
+// disconnected rather than slowing down the others; it can resume from its Last-Event-ID.
+func (b *Broker) Broadcast(e outbox.Event) {
+	if e.AggregateType != outbox.AggregateMission {
+		return
+	}
+
+	var payload struct {
+		CatID         *int `json:"cat_id"`
+		PreviousCatID *int `json:"previous_cat_id"`
+	}
+	_ = json.Unmarshal(e.Payload, &payload)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Events are published at least once; a repeat has already been sent.
+	if _, ok := b.ids[e.ID]; ok || b.closed {
+		return
+	}
+
+	b.buffer = append(b.buffer, event{Event: e, catID: payload.CatID, previousCatID: payload.PreviousCatID})
+	b.ids[e.ID] = struct{}{}
+	if len(b.buffer) > b.cfg.BufferSize {
+		delete(b.ids, b.buffer[0].ID)
+		b.buffer = b.buffer[1:]
+	}
+
+	for s := range b.clients {
+		if !s.filter.matches(b.buffer[len(b.buffer)-1]) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			b.drop(s)
+		}
+	}
+}
+
+// Reset forgets the buffered events and ends every stream, after events may have been missed. Clients
+// reconnect and are told to reload.
+func (b *Broker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buffer = nil
+	clear(b.ids)
+	for s := range b.clients {
+		b.drop(s)
+	}
+}
+
+// Close ends every stream, so that the server can shut down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.clients {
+		b.drop(s)
+	}
+}
+
+func (b *Broker) drop(s *Subscription) {
+	if _, ok := b.clients[s]; !ok {
+		return
+	}
+
+	delete(b.clients, s)
+	close(s.events)
+	metrics.StreamClients.Dec()
+}
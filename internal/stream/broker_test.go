@@ -0,0 +1,186 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/outbox"
+	"testing"
+)
+
+var testConfig = config.StreamConfig{MaxClients: 10, BufferSize: 5, ClientBuffer: 10}
+
+func ptr(v int) *int {
+	return &v
+}
+
+func handlerContext() context.Context {
+	return auth.NewContext(context.Background(), &auth.Principal{Subject: "user:1", Role: auth.RoleHandler})
+}
+
+func agentContext(catID int) context.Context {
+	return auth.NewContext(context.Background(), &auth.Principal{Subject: "user:2", Role: auth.RoleFieldAgent, CatID: &catID})
+}
+
+func missionEvent(id int64, missionID int, eventType string, payload any) outbox.Event {
+	raw, _ := json.Marshal(payload)
+	return outbox.Event{ID: id, AggregateType: outbox.AggregateMission, AggregateID: missionID, Type: eventType, Payload: raw}
+}
+
+// received returns the IDs of the events waiting on s, and whether its stream has been closed.
+func received(s *Subscription) (ids []int64, closed bool) {
+	for {
+		select {
+		case e, ok := <-s.Events():
+			if !ok {
+				return ids, true
+			}
+			ids = append(ids, e.ID)
+		default:
+			return ids, false
+		}
+	}
+}
+
+func TestBrokerFanOut(t *testing.T) {
+	events := []outbox.Event{
+		missionEvent(1, 7, outbox.MissionCreated, map[string]any{"mission_id": 7}),
+		missionEvent(2, 7, outbox.MissionAssigned, map[string]any{"mission_id": 7, "cat_id": 3, "previous_cat_id": nil}),
+		missionEvent(3, 8, outbox.TargetUpdated, map[string]any{"mission_id": 8, "target_id": 1, "cat_id": 4}),
+		missionEvent(4, 7, outbox.MissionAssigned, map[string]any{"mission_id": 7, "cat_id": 4, "previous_cat_id": 3}),
+		missionEvent(5, 7, outbox.TargetCompleted, map[string]any{"mission_id": 7, "target_id": 2, "cat_id": 4}),
+		// Cat events are not streamed, and a republished event is not sent again.
+		{ID: 6, AggregateType: outbox.AggregateCat, AggregateID: 3, Type: outbox.CatHired, Payload: json.RawMessage(`{"cat_id":3}`)},
+		missionEvent(3, 8, outbox.TargetUpdated, map[string]any{"mission_id": 8, "target_id": 1, "cat_id": 4}),
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		filter Filter
+		want   []int64
+	}{
+		{"everything", handlerContext(), Filter{}, []int64{1, 2, 3, 4, 5}},
+		{"one mission", handlerContext(), Filter{MissionID: ptr(7)}, []int64{1, 2, 4, 5}},
+		{"one cat", handlerContext(), Filter{CatID: ptr(4)}, []int64{3, 4, 5}},
+		{"field agent of the new cat", agentContext(4), Filter{}, []int64{3, 4, 5}},
+		{"field agent the mission was taken from", agentContext(3), Filter{}, []int64{2, 4}},
+		{"field agent of another cat", agentContext(9), Filter{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBroker(testConfig)
+			s, err := b.Subscribe(tt.ctx, tt.filter, nil)
+			if err != nil {
+				t.Fatalf("Subscribe() error = %v", err)
+			}
+
+			for _, e := range events {
+				b.Broadcast(e)
+			}
+
+			ids, closed := received(s)
+			if closed {
+				t.Error("stream was closed")
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("received %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrokerSubscribeRestrictions(t *testing.T) {
+	b := NewBroker(config.StreamConfig{MaxClients: 1, BufferSize: 5, ClientBuffer: 10})
+
+	if _, err := b.Subscribe(agentContext(3), Filter{CatID: ptr(4)}, nil); !errors.Is(err, auth.ForbiddenErr) {
+		t.Errorf("field agent following another cat: error = %v, want %v", err, auth.ForbiddenErr)
+	}
+	if _, err := b.Subscribe(handlerContext(), Filter{}, nil); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if _, err := b.Subscribe(handlerContext(), Filter{}, nil); !errors.Is(err, TooManyClientsErr) {
+		t.Errorf("beyond max_clients: error = %v, want %v", err, TooManyClientsErr)
+	}
+}
+
+func TestBrokerReplay(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastEventID int64
+		filter      Filter
+		replay      []int64
+		missed      bool
+		latest      int64
+	}{
+		{name: "after a buffered event", lastEventID: 5, replay: []int64{6, 7}},
+		{name: "filtered", lastEventID: 3, filter: Filter{MissionID: ptr(2)}, replay: []int64{5, 7}},
+		{name: "up to date", lastEventID: 7},
+		{name: "evicted", lastEventID: 2, missed: true, latest: 7},
+		{name: "unknown", lastEventID: 42, missed: true, latest: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBroker(testConfig)
+			for id := int64(1); id <= 7; id++ {
+				missionID := 1 + int(id)%2
+				b.Broadcast(missionEvent(id, missionID, outbox.TargetUpdated, map[string]any{"mission_id": missionID}))
+			}
+
+			s, err := b.Subscribe(handlerContext(), tt.filter, &tt.lastEventID)
+			if err != nil {
+				t.Fatalf("Subscribe() error = %v", err)
+			}
+
+			var replay []int64
+			for _, e := range s.Replay {
+				replay = append(replay, e.ID)
+			}
+			if !slices.Equal(replay, tt.replay) || s.Missed != tt.missed || s.Latest != tt.latest {
+				t.Errorf("replay %v, missed %t, latest %d; want %v, %t, %d",
+					replay, s.Missed, s.Latest, tt.replay, tt.missed, tt.latest)
+			}
+		})
+	}
+}
+
+func TestBrokerDropsSlowClients(t *testing.T) {
+	b := NewBroker(config.StreamConfig{MaxClients: 10, BufferSize: 5, ClientBuffer: 2})
+
+	slow, err := b.Subscribe(handlerContext(), Filter{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := b.Subscribe(handlerContext(), Filter{MissionID: ptr(1)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id := int64(1); id <= 3; id++ {
+		b.Broadcast(missionEvent(id, int(id), outbox.MissionCreated, map[string]any{"mission_id": id}))
+	}
+
+	ids, closed := received(slow)
+	if !closed || !slices.Equal(ids, []int64{1, 2}) {
+		t.Errorf("slow client: received %v, closed %t; want [1 2] and closed", ids, closed)
+	}
+	ids, closed = received(other)
+	if closed || !slices.Equal(ids, []int64{1}) {
+		t.Errorf("other client: received %v, closed %t; want [1] and open", ids, closed)
+	}
+
+	// The dropped client resumes from the last event it got.
+	last := int64(2)
+	resumed, err := b.Subscribe(handlerContext(), Filter{}, &last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.Replay) != 1 || resumed.Replay[0].ID != 3 || resumed.Missed {
+		t.Errorf("resumed with replay %v, missed %t", resumed.Replay, resumed.Missed)
+	}
+}
@@ -0,0 +1,117 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"spy-cat-agency/internal/outbox"
+	"strconv"
+	"time"
+)
+
+const ResetEvent = "stream.reset"
+
+type StreamRequest struct {
+	MissionID *int `form:"mission_id"`
+	CatID     *int `form:"cat_id"`
+}
+
+type Handler struct {
+	Broker *Broker
+}
+
+func NewHandler(broker *Broker) *Handler {
+	return &Handler{Broker: broker}
+}
+
+func (h *Handler) Stream(c *gin.Context) {
+	var request StreamRequest
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	var lastEventID *int64
+	if header := c.GetHeader("Last-Event-ID"); header != "" {
+		// An ID that is not ours is never buffered, so the client is told to reload.
+		id, _ := strconv.ParseInt(header, 10, 64)
+		lastEventID = &id
+	}
+
+	ctx := c.Request.Context()
+
+	s, err := h.Broker.Subscribe(ctx, Filter{MissionID: request.MissionID, CatID: request.CatID}, lastEventID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	defer h.Broker.Unsubscribe(s)
+
+	// The server's read and write timeouts would cut the stream off.
+	rc := http.NewResponseController(c.Writer)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if s.Missed {
+		if err = writeReset(c.Writer, s.Latest); err != nil {
+			return
+		}
+	}
+	for _, e := range s.Replay {
+		if err = writeEvent(c.Writer, e); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.Broker.cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-s.Events():
+			if !ok {
+				return
+			}
+			err = writeEvent(c.Writer, e)
+		case <-heartbeat.C:
+			_, err = io.WriteString(c.Writer, ": heartbeat\n\n")
+		}
+		if err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeEvent(w io.Writer, e outbox.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+// writeReset tells the client that events may have been missed. It carries the ID to resume from, so
+// that the next reconnect does not reset again.
+func writeReset(w io.Writer, latest int64) error {
+	id := ""
+	if latest != 0 {
+		id = strconv.FormatInt(latest, 10)
+	}
+
+	_, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: {}\n\n", id, ResetEvent)
+	return err
+}
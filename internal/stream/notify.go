@@ -0,0 +1,126 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/lib/pq"
+	"log/slog"
+	"spy-cat-agency/internal/db"
+	"spy-cat-agency/internal/outbox"
+	"time"
+)
+
+// Channel is the Postgres notification channel events are broadcast on to every server.
+const Channel = "spycat_events"
+
+const pingInterval = 90 * time.Second
+
+// maxNotifyBytes stays below the 8000 bytes Postgres accepts as a notification payload.
+const maxNotifyBytes = 7900
+
+// Notifier is the outbox publisher of the stream. The notification is sent in the dispatcher's
+// transaction, so Postgres delivers it only once the event is marked as published, to every server in
+// commit order. It is best-effort: a failure is logged and does not fail the event for the other publishers.
+type Notifier struct {
+	conn *db.DB
+}
+
+func NewNotifier(conn *db.DB) *Notifier {
+	return &Notifier{conn: conn}
+}
+
+func (n *Notifier) Publish(ctx context.Context, e outbox.Event) error {
+	if e.AggregateType != outbox.AggregateMission {
+		return nil
+	}
+
+	payload, err := notification(e)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to encode stream event", "event_id", e.ID, "error", err)
+		return nil
+	}
+
+	err = n.conn.InSavepoint(ctx, func(ctx context.Context) error {
+		_, err := n.conn.ExecContext(ctx, `SELECT pg_notify($1, $2)`, Channel, payload)
+		return err
+	})
+	if err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "Failed to notify stream event", "event_id", e.ID, "error", err)
+		return nil
+	}
+
+	return err
+}
+
+// notification encodes e, cutting its payload down to the ids streams filter on when it would not fit.
+func notification(e outbox.Event) (string, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) <= maxNotifyBytes {
+		return string(payload), nil
+	}
+
+	var ids struct {
+		MissionID     *int `json:"mission_id,omitempty"`
+		TargetID      *int `json:"target_id,omitempty"`
+		CatID         *int `json:"cat_id,omitempty"`
+		PreviousCatID *int `json:"previous_cat_id,omitempty"`
+	}
+	if err := json.Unmarshal(e.Payload, &ids); err != nil {
+		return "", err
+	}
+	if e.Payload, err = json.Marshal(ids); err != nil {
+		return "", err
+	}
+
+	payload, err = json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) > maxNotifyBytes {
+		return "", fmt.Errorf("notification of %d bytes is too large", len(payload))
+	}
+
+	return string(payload), nil
+}
+
+// Listen feeds the broker from the notification channel until ctx is cancelled.
+func (b *Broker) Listen(ctx context.Context, l *pq.Listener) {
+	defer l.Close()
+
+	if err := l.Listen(Channel); err != nil {
+		slog.ErrorContext(ctx, "Failed to listen for stream events", "error", err)
+		return
+	}
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-l.Notify:
+			if n == nil {
+				// The connection was re-established: anything sent while it was down is lost.
+				slog.WarnContext(ctx, "Stream listener reconnected, resetting streams")
+				b.Reset()
+				continue
+			}
+
+			var e outbox.Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				slog.ErrorContext(ctx, "Failed to decode stream event", "error", err)
+				continue
+			}
+			b.Broadcast(e)
+		case <-ping.C:
+			go func() {
+				_ = l.Ping()
+			}()
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"spy-cat-agency/config"
+	"spy-cat-agency/internal/metrics"
+	"sync"
+	"time"
+)
+
+type Deliverer struct {
+	repo   *Repository
+	sender *Sender
+	cfg    config.WebhooksConfig
+}
+
+func NewDeliverer(repo *Repository, cfg config.WebhooksConfig) *Deliverer {
+	return &Deliverer{
+		repo:   repo,
+		sender: NewSender(cfg.Timeout),
+		cfg:    cfg,
+	}
+}
+
+// Run sends due deliveries every poll interval until ctx is cancelled.
+func (d *Deliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			n, err := d.deliverBatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.ErrorContext(ctx, "Failed to claim webhook deliveries", "error", err)
+				}
+				break
+			}
+			if n < d.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Deliverer) deliverBatch(ctx context.Context) (int, error) {
+	// Every request of the batch runs in parallel and is bounded by the timeout, so the lease outlives them.
+	claims, err := d.repo.ClaimDeliveries(ctx, d.cfg.BatchSize, time.Now().Add(2*d.cfg.Timeout))
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range claims {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	return len(claims), nil
+}
+
+func (d *Deliverer) deliver(ctx context.Context, c claim) {
+	status, err := d.sender.Send(ctx, c.URL, c.Secret, c.Delivery)
+	if ctx.Err() != nil {
+		// Shutting down: the lease expires and the delivery is attempted again on the next start.
+		return
+	}
+
+	if err == nil {
+		metrics.WebhookDeliveries.WithLabelValues("delivered").Inc()
+		if err = d.repo.MarkDelivered(ctx, c.ID, status); err != nil {
+			slog.ErrorContext(ctx, "Failed to record webhook delivery", "delivery_id", c.ID, "error", err)
+		}
+		return
+	}
+
+	var statusCode *int
+	if status != 0 {
+		statusCode = &status
+	}
+
+	attempts := c.Attempts + 1
+	next, outcome := StatusPending, "retried"
+	nextAttemptAt := time.Now().Add(d.backoff(attempts))
+	if attempts >= d.cfg.MaxAttempts {
+		next, outcome = StatusFailed, "failed"
+		nextAttemptAt = time.Now()
+		slog.WarnContext(ctx, "Webhook delivery failed permanently",
+			"delivery_id", c.ID, "subscription_id", c.SubscriptionID, "attempts", attempts, "error", err)
+	}
+
+	metrics.WebhookDeliveries.WithLabelValues(outcome).Inc()
+	if err = d.repo.MarkFailed(ctx, c.ID, next, nextAttemptAt, statusCode, err.Error()); err != nil {
+		slog.ErrorContext(ctx, "Failed to record webhook delivery", "delivery_id", c.ID, "error", err)
+	}
+}
+
+func (d *Deliverer) backoff(attempts int) time.Duration {
+	delay := d.cfg.RetryBackoff
+	for i := 1; i < attempts && delay < d.cfg.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxRetryBackoff)
+}
@@ -0,0 +1,252 @@
+package webhook
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"time"
+)
+
+type WebhookResponse struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ListWebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type CreateWebhookResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+type UpdateSubscriptionRequest struct {
+	URL    *string  `json:"url"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+type ListDeliveriesRequest struct {
+	Status Status `form:"status"`
+	Limit  int    `form:"limit"`
+}
+
+type DeliveryResponse struct {
+	ID             int64           `json:"id"`
+	EventID        int64           `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         Status          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+type ListDeliveriesResponse struct {
+	Deliveries []DeliveryResponse `json:"deliveries"`
+}
+
+type Handler struct {
+	Service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{Service: service}
+}
+
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	subscriptions, err := h.Service.ListSubscriptions(ctx)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := ListWebhooksResponse{Webhooks: make([]WebhookResponse, 0, len(subscriptions))}
+	for _, s := range subscriptions {
+		response.Webhooks = append(response.Webhooks, toResponse(&s))
+	}
+
+	c.JSON(200, response)
+}
+
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var request CreateWebhookRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	subscription, err := h.Service.CreateSubscription(ctx, request.URL, request.Events)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := CreateWebhookResponse{
+		WebhookResponse: toResponse(subscription),
+		Secret:          subscription.Secret,
+	}
+
+	c.JSON(201, response)
+}
+
+func (h *Handler) GetWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(NotFoundErr)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	subscription, err := h.Service.GetSubscription(ctx, id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(200, toResponse(subscription))
+}
+
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(NotFoundErr)
+		return
+	}
+
+	var request UpdateSubscriptionRequest
+	err = c.ShouldBindJSON(&request)
+	if err != nil {
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	subscription, err := h.Service.UpdateSubscription(ctx, id, request)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(200, toResponse(subscription))
+}
+
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(NotFoundErr)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	err = h.Service.DeleteSubscription(ctx, id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+func (h *Handler) ListDeliveries(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(NotFoundErr)
+		return
+	}
+
+	var request ListDeliveriesRequest
+	err = c.ShouldBindQuery(&request)
+	if err != nil {
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	deliveries, err := h.Service.ListDeliveries(ctx, id, request.Status, request.Limit)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := ListDeliveriesResponse{Deliveries: make([]DeliveryResponse, 0, len(deliveries))}
+	for _, d := range deliveries {
+		response.Deliveries = append(response.Deliveries, toDeliveryResponse(&d))
+	}
+
+	c.JSON(200, response)
+}
+
+func (h *Handler) Redeliver(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(NotFoundErr)
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		_ = c.Error(DeliveryNotFoundErr)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	delivery, err := h.Service.Redeliver(ctx, id, deliveryID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(202, toDeliveryResponse(delivery))
+}
+
+func toResponse(s *Subscription) WebhookResponse {
+	return WebhookResponse{
+		ID:        s.ID,
+		URL:       s.URL,
+		Events:    s.Events,
+		Active:    s.Active,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+func toDeliveryResponse(d *Delivery) DeliveryResponse {
+	response := DeliveryResponse{
+		ID:             d.ID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+	if d.Status == StatusPending {
+		response.NextAttemptAt = &d.NextAttemptAt
+	}
+	return response
+}
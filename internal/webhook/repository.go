@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"spy-cat-agency/internal/db"
+	"time"
+)
+
+type Repository struct {
+	conn *db.DB
+}
+
+func NewRepository(conn *db.DB) *Repository {
+	return &Repository{conn: conn}
+}
+
+const (
+	subscriptionColumns = `id, url, secret, events, active, created_at`
+	deliveryColumns     = `id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+		last_status_code, last_error, created_at, delivered_at`
+)
+
+func (r *Repository) CreateSubscription(ctx context.Context, s *Subscription) error {
+	query := `INSERT INTO webhook_subscriptions (url, secret, events, active) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+
+	return r.conn.QueryRowContext(ctx, query, s.URL, s.Secret, pq.Array(s.Events), s.Active).Scan(&s.ID, &s.CreatedAt)
+}
+
+func (r *Repository) GetAllSubscriptions(ctx context.Context) ([]Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscriptions ORDER BY id`
+
+	rows, err := r.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := make([]Subscription, 0)
+
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, *s)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (r *Repository) GetSubscriptionByID(ctx context.Context, id int) (*Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1`
+
+	return scanSubscription(r.conn.QueryRowContext(ctx, query, id))
+}
+
+func (r *Repository) UpdateSubscription(ctx context.Context, s *Subscription) error {
+	query := `UPDATE webhook_subscriptions SET url = $1, events = $2, active = $3 WHERE id = $4`
+
+	res, err := r.conn.ExecContext(ctx, query, s.URL, pq.Array(s.Events), s.Active, s.ID)
+	if err != nil {
+		return err
+	}
+
+	return expectRow(res)
+}
+
+func (r *Repository) DeleteSubscription(ctx context.Context, id int) error {
+	query := `DELETE FROM webhook_subscriptions WHERE id = $1`
+
+	res, err := r.conn.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return expectRow(res)
+}
+
+// CreateDeliveries queues payload for every active subscription to eventType. An event that was already
+// queued for a subscription is skipped.
+func (r *Repository) CreateDeliveries(ctx context.Context, eventID int64, eventType string, payload []byte) (int64, error) {
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT id, $1::bigint, $2::text, $3::jsonb FROM webhook_subscriptions WHERE active AND $2::text = ANY(events)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`
+
+	res, err := r.conn.ExecContext(ctx, query, eventID, eventType, string(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (r *Repository) GetDeliveries(ctx context.Context, subscriptionID int, status Status, limit int) ([]Delivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2::text = '' OR status = $2::text)
+		ORDER BY id DESC
+		LIMIT $3`
+
+	rows, err := r.conn.QueryContext(ctx, query, subscriptionID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]Delivery, 0)
+
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ResetDelivery makes a delivery pending again with a fresh set of attempts, due immediately.
+func (r *Repository) ResetDelivery(ctx context.Context, subscriptionID int, id int64) (*Delivery, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+		WHERE subscription_id = $1 AND id = $2
+		RETURNING ` + deliveryColumns
+
+	return scanDelivery(r.conn.QueryRowContext(ctx, query, subscriptionID, id))
+}
+
+type claim struct {
+	Delivery
+	URL    string
+	Secret string
+}
+
+// ClaimDeliveries leases up to limit due deliveries until leaseUntil, so that other workers skip them while
+// they are being sent. A delivery whose worker dies becomes due again when the lease runs out. Deliveries of
+// inactive subscriptions wait until the subscription is activated again.
+func (r *Repository) ClaimDeliveries(ctx context.Context, limit int, leaseUntil time.Time) ([]claim, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = $2
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id
+			AND d.id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= NOW()
+					AND subscription_id IN (SELECT id FROM webhook_subscriptions WHERE active)
+				ORDER BY next_attempt_at, id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.attempts, s.url, s.secret`
+
+	rows, err := r.conn.QueryContext(ctx, query, limit, leaseUntil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claims := make([]claim, 0)
+
+	for rows.Next() {
+		var c claim
+		var payload []byte
+		err = rows.Scan(&c.ID, &c.SubscriptionID, &c.EventID, &c.EventType, &payload, &c.Attempts, &c.URL, &c.Secret)
+		if err != nil {
+			return nil, err
+		}
+		c.Payload = payload
+		claims = append(claims, c)
+	}
+
+	return claims, rows.Err()
+}
+
+func (r *Repository) MarkDelivered(ctx context.Context, id int64, statusCode int) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = NOW()
+		WHERE id = $1`
+
+	_, err := r.conn.ExecContext(ctx, query, id, statusCode)
+	return err
+}
+
+// MarkFailed records a failed attempt. status is pending while attempts remain, failed to dead-letter the delivery.
+func (r *Repository) MarkFailed(ctx context.Context, id int64, status Status, nextAttemptAt time.Time, statusCode *int, reason string) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_status_code = $4, last_error = $5
+		WHERE id = $1`
+
+	_, err := r.conn.ExecContext(ctx, query, id, status, nextAttemptAt, statusCode, reason)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubscription(row scanner) (*Subscription, error) {
+	var s Subscription
+
+	err := row.Scan(&s.ID, &s.URL, &s.Secret, pq.Array(&s.Events), &s.Active, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func scanDelivery(row scanner) (*Delivery, error) {
+	var d Delivery
+	var payload []byte
+
+	err := row.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+
+	return &d, nil
+}
+
+func expectRow(res sql.Result) error {
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Spycat-Signature"
+	EventHeader     = "X-Spycat-Event"
+	DeliveryHeader  = "X-Spycat-Delivery"
+)
+
+var InvalidSignatureErr = errors.New("invalid webhook signature")
+
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(timeout time.Duration) *Sender {
+	return &Sender{client: &http.Client{Timeout: timeout}}
+}
+
+// Send posts a delivery to url. It returns the response status, or 0 when no response was received, and an
+// error unless the endpoint answered with a 2xx status.
+func (s *Sender) Send(ctx context.Context, url, secret string, d Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "spy-cat-agency-webhooks")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header of a request body sent at timestamp: "t=<unix seconds>,v1=<hex>", where
+// v1 is the HMAC-SHA256 of "<unix seconds>.<body>" keyed with the subscription secret.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + mac(secret, t, body)
+}
+
+// Verify checks a signature header the way a receiver should, rejecting signatures older than tolerance to
+// prevent replays.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			v1 = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing timestamp", InvalidSignatureErr)
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", InvalidSignatureErr)
+	}
+
+	if !hmac.Equal([]byte(v1), []byte(mac(secret, t, body))) {
+		return InvalidSignatureErr
+	}
+
+	return nil
+}
+
+func mac(secret, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testSecret = "whsec_test"
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"delivered", http.StatusNoContent, false},
+		{"server error", http.StatusServiceUnavailable, true},
+		{"client error", http.StatusGone, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := Delivery{
+				ID:        42,
+				EventType: "target.completed",
+				Payload:   json.RawMessage(`{"id":7,"type":"target.completed"}`),
+			}
+
+			var received bool
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = true
+
+				body, _ := io.ReadAll(r.Body)
+				if err := Verify(testSecret, r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+					t.Errorf("Verify() = %v", err)
+				}
+				if got := r.Header.Get(EventHeader); got != delivery.EventType {
+					t.Errorf("%s = %q, want %q", EventHeader, got, delivery.EventType)
+				}
+				if got := r.Header.Get(DeliveryHeader); got != "42" {
+					t.Errorf("%s = %q, want %q", DeliveryHeader, got, "42")
+				}
+				if string(body) != string(delivery.Payload) {
+					t.Errorf("body = %s, want %s", body, delivery.Payload)
+				}
+
+				w.WriteHeader(tt.status)
+			}))
+			defer receiver.Close()
+
+			status, err := NewSender(time.Second).Send(context.Background(), receiver.URL, testSecret, delivery)
+			if !received {
+				t.Fatal("receiver was not called")
+			}
+			if status != tt.status {
+				t.Errorf("Send() status = %d, want %d", status, tt.status)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSendUnreachable(t *testing.T) {
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	receiver.Close()
+
+	status, err := NewSender(time.Second).Send(context.Background(), receiver.URL, testSecret, Delivery{})
+	if err == nil || status != 0 {
+		t.Errorf("Send() = %d, %v, want 0 and an error", status, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		valid  bool
+	}{
+		{"valid", testSecret, Sign(testSecret, now, body), body, true},
+		{"wrong secret", "whsec_other", Sign(testSecret, now, body), body, false},
+		{"tampered body", testSecret, Sign(testSecret, now, body), []byte(`{"id":2}`), false},
+		{"expired", testSecret, Sign(testSecret, now.Add(-time.Hour), body), body, false},
+		{"missing timestamp", testSecret, "v1=abc", body, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute)
+			if tt.valid && err != nil {
+				t.Errorf("Verify() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, InvalidSignatureErr) {
+				t.Errorf("Verify() = %v, want %v", err, InvalidSignatureErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"spy-cat-agency/internal/auth"
+	"spy-cat-agency/internal/db"
+	"spy-cat-agency/internal/outbox"
+	"spy-cat-agency/internal/tracing"
+)
+
+const (
+	secretPrefix        = "whsec_"
+	defaultDeliveryPage = 100
+)
+
+var (
+	NotFoundErr         = errors.New("webhook not found")
+	DeliveryNotFoundErr = errors.New("webhook delivery not found")
+	InvalidEventErr     = errors.New("unknown event type")
+	InvalidURLErr       = errors.New("webhook url must be an absolute http or https url")
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+func (s *Service) CreateSubscription(ctx context.Context, rawURL string, events []string) (*Subscription, error) {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "webhook.Service.CreateSubscription")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.WebhooksManage); err != nil {
+		return nil, err
+	}
+
+	if err := validate(rawURL, events); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	subscription := &Subscription{
+		URL:    rawURL,
+		Secret: secretPrefix + hex.EncodeToString(secret),
+		Events: slices.Compact(slices.Sorted(slices.Values(events))),
+		Active: true,
+	}
+
+	if err := s.repo.CreateSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (s *Service) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	ctx, span := tracing.Start(ctx, "webhook.Service.ListSubscriptions")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.WebhooksManage); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetAllSubscriptions(ctx)
+}
+
+func (s *Service) GetSubscription(ctx context.Context, id int) (*Subscription, error) {
+	ctx, span := tracing.Start(ctx, "webhook.Service.GetSubscription")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.WebhooksManage); err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.repo.GetSubscriptionByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, NotFoundErr
+	}
+
+	return subscription, err
+}
+
+func (s *Service) UpdateSubscription(ctx context.Context, id int, r UpdateSubscriptionRequest) (*Subscription, error) {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "webhook.Service.UpdateSubscription")
+	defer span.End()
+
+	subscription, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.URL != nil {
+		subscription.URL = *r.URL
+	}
+	if r.Events != nil {
+		subscription.Events = slices.Compact(slices.Sorted(slices.Values(r.Events)))
+	}
+	if r.Active != nil {
+		subscription.Active = *r.Active
+	}
+
+	if err = validate(subscription.URL, subscription.Events); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.UpdateSubscription(ctx, subscription)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, NotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (s *Service) DeleteSubscription(ctx context.Context, id int) error {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "webhook.Service.DeleteSubscription")
+	defer span.End()
+
+	if _, err := auth.Authorize(ctx, auth.WebhooksManage); err != nil {
+		return err
+	}
+
+	err := s.repo.DeleteSubscription(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFoundErr
+	}
+
+	return err
+}
+
+func (s *Service) ListDeliveries(ctx context.Context, subscriptionID int, status Status, limit int) ([]Delivery, error) {
+	ctx, span := tracing.Start(ctx, "webhook.Service.ListDeliveries")
+	defer span.End()
+
+	if _, err := s.GetSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultDeliveryPage
+	}
+
+	return s.repo.GetDeliveries(ctx, subscriptionID, status, limit)
+}
+
+// Redeliver queues a delivery again, typically a dead-lettered one after the endpoint has been fixed. It
+// starts over with the full number of attempts.
+func (s *Service) Redeliver(ctx context.Context, subscriptionID int, deliveryID int64) (*Delivery, error) {
+	ctx, span := tracing.Start(db.WithPrimary(ctx), "webhook.Service.Redeliver")
+	defer span.End()
+
+	if _, err := s.GetSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.repo.ResetDelivery(ctx, subscriptionID, deliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, DeliveryNotFoundErr
+	}
+
+	return delivery, err
+}
+
+// Publish queues an outbox event for every subscription to its type. It is called by the outbox dispatcher
+// inside the transaction that marks the event as published, so each event is queued exactly once. A failing
+// insert is rolled back to the dispatcher's savepoint and the event is retried.
+func (s *Service) Publish(ctx context.Context, e outbox.Event) error {
+	ctx, span := tracing.Start(ctx, "webhook.Service.Publish")
+	defer span.End()
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.repo.CreateDeliveries(ctx, e.ID, e.Type, payload)
+	return err
+}
+
+func validate(rawURL string, events []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return InvalidURLErr
+	}
+
+	for _, event := range events {
+		if !slices.Contains(Events, event) {
+			return fmt.Errorf("%w %q", InvalidEventErr, event)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"encoding/json"
+	"spy-cat-agency/internal/outbox"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusDelivered Status = "delivered"
+	StatusFailed    Status = "failed"
+)
+
+// Events are the event types a subscription may ask for.
+var Events = []string{
+	outbox.CatHired,
+	outbox.CatSalaryChanged,
+	outbox.CatDeleted,
+	outbox.MissionCreated,
+	outbox.MissionAssigned,
+	outbox.MissionCompleted,
+	outbox.MissionDeleted,
+	outbox.TargetAdded,
+	outbox.TargetUpdated,
+	outbox.TargetCompleted,
+	outbox.TargetDeleted,
+}
+
+type Subscription struct {
+	ID        int
+	URL       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+}
+
+type Delivery struct {
+	ID             int64
+	SubscriptionID int
+	EventID        int64
+	EventType      string
+	Payload        json.RawMessage
+	Status         Status
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode *int
+	LastError      *string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
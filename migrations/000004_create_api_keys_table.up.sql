@@ -0,0 +1,23 @@
+CREATE TABLE api_keys (
+                          id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+
+                          name VARCHAR(255) NOT NULL,
+
+                          prefix VARCHAR(16) NOT NULL,
+                          key_hash CHAR(64) NOT NULL UNIQUE,
+
+                          scopes TEXT[] NOT NULL DEFAULT '{}',
+
+                          created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                          expires_at TIMESTAMPTZ,
+                          last_used_at TIMESTAMPTZ,
+                          revoked_at TIMESTAMPTZ
+);
+
+COMMENT ON TABLE api_keys IS 'Long-lived credentials for machine clients.';
+COMMENT ON COLUMN api_keys.prefix IS 'The first characters of the key, kept to recognise it in listings.';
+COMMENT ON COLUMN api_keys.key_hash IS 'Hex-encoded SHA-256 of the key; the key itself is only shown once on creation.';
+COMMENT ON COLUMN api_keys.scopes IS 'Permissions granted to the key, e.g. cats:read or missions:write.';
+COMMENT ON COLUMN api_keys.expires_at IS 'The key is rejected after this time. NULL if it never expires.';
+COMMENT ON COLUMN api_keys.last_used_at IS 'Last successful authentication, updated at most once a minute.';
+COMMENT ON COLUMN api_keys.revoked_at IS 'The time the key was revoked. NULL while it is active.';
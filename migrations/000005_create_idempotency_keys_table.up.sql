@@ -0,0 +1,23 @@
+CREATE TABLE idempotency_keys (
+                                  scope VARCHAR(255) NOT NULL,
+                                  key VARCHAR(255) NOT NULL,
+
+                                  fingerprint CHAR(64) NOT NULL,
+
+                                  status_code INTEGER,
+                                  content_type VARCHAR(255),
+                                  response_body BYTEA,
+
+                                  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                  expires_at TIMESTAMPTZ NOT NULL,
+
+                                  PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+COMMENT ON TABLE idempotency_keys IS 'Responses to POST requests sent with an Idempotency-Key header, replayed on retries.';
+COMMENT ON COLUMN idempotency_keys.scope IS 'The authenticated client that sent the key, so keys of different clients never collide.';
+COMMENT ON COLUMN idempotency_keys.fingerprint IS 'Hex-encoded SHA-256 of the method, path and body of the original request.';
+COMMENT ON COLUMN idempotency_keys.status_code IS 'Status of the stored response. NULL while the original request is still being processed.';
+COMMENT ON COLUMN idempotency_keys.expires_at IS 'After this time the key is deleted and may be reused.';
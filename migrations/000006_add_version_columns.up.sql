@@ -0,0 +1,7 @@
+ALTER TABLE cats ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE missions ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE targets ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+COMMENT ON COLUMN cats.version IS 'Incremented on every update; exposed as the ETag of the cat.';
+COMMENT ON COLUMN missions.version IS 'Incremented on every update of the mission or one of its targets; exposed as the ETag of the mission.';
+COMMENT ON COLUMN targets.version IS 'Incremented on every update; checked against the If-Match header of target updates.';
\ No newline at end of file
@@ -0,0 +1,36 @@
+CREATE TABLE audit_events (
+                              id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+
+                              occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+                              actor VARCHAR(255) NOT NULL,
+                              request_id VARCHAR(128),
+
+                              entity_type VARCHAR(32) NOT NULL,
+                              entity_id INTEGER NOT NULL,
+                              action VARCHAR(16) NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+
+                              before JSONB,
+                              after JSONB
+);
+
+CREATE INDEX audit_events_entity_idx ON audit_events (entity_type, entity_id);
+CREATE INDEX audit_events_actor_idx ON audit_events (actor);
+CREATE INDEX audit_events_occurred_at_idx ON audit_events (occurred_at);
+
+CREATE FUNCTION audit_events_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+COMMENT ON TABLE audit_events IS 'Append-only record of every create, update and delete of cats, missions and targets.';
+COMMENT ON COLUMN audit_events.actor IS 'Subject of the token or API key (api_key:<id>) that made the change.';
+COMMENT ON COLUMN audit_events.request_id IS 'The X-Request-ID of the request that made the change.';
+COMMENT ON COLUMN audit_events.entity_type IS 'cat, mission or target.';
+COMMENT ON COLUMN audit_events.before IS 'The fields that changed, with their old values. NULL for creates.';
+COMMENT ON COLUMN audit_events.after IS 'The fields that changed, with their new values. NULL for deletes.';
\ No newline at end of file
@@ -0,0 +1,27 @@
+CREATE TABLE outbox (
+                        id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+
+                        aggregate_type VARCHAR(32) NOT NULL,
+                        aggregate_id INTEGER NOT NULL,
+
+                        event_type VARCHAR(64) NOT NULL,
+                        payload JSONB NOT NULL,
+
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        published_at TIMESTAMPTZ,
+
+                        attempts INTEGER NOT NULL DEFAULT 0,
+                        next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        last_error TEXT
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (aggregate_type, aggregate_id, id) WHERE published_at IS NULL;
+CREATE INDEX outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+COMMENT ON TABLE outbox IS 'Domain events written in the same transaction as the change they describe, waiting to be published.';
+COMMENT ON COLUMN outbox.aggregate_type IS 'cat or mission; events of one aggregate are published in id order.';
+COMMENT ON COLUMN outbox.event_type IS 'e.g. cat.hired, cat.salary_changed, mission.assigned, target.completed, mission.completed.';
+COMMENT ON COLUMN outbox.published_at IS 'When the event was published. NULL while it is pending.';
+COMMENT ON COLUMN outbox.attempts IS 'Failed publish attempts so far.';
+COMMENT ON COLUMN outbox.next_attempt_at IS 'The event is not retried before this time.';
+COMMENT ON COLUMN outbox.last_error IS 'Error of the last failed publish attempt.';
\ No newline at end of file
@@ -0,0 +1,45 @@
+CREATE TABLE webhook_subscriptions (
+                                       id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+
+                                       url TEXT NOT NULL,
+                                       secret VARCHAR(128) NOT NULL,
+
+                                       events TEXT[] NOT NULL,
+                                       active BOOLEAN NOT NULL DEFAULT TRUE,
+
+                                       created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE webhook_deliveries (
+                                    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+
+                                    subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+
+                                    event_id BIGINT NOT NULL,
+                                    event_type VARCHAR(64) NOT NULL,
+                                    payload JSONB NOT NULL,
+
+                                    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+                                    attempts INTEGER NOT NULL DEFAULT 0,
+                                    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                    last_status_code INTEGER,
+                                    last_error TEXT,
+
+                                    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                    delivered_at TIMESTAMPTZ,
+
+                                    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+COMMENT ON TABLE webhook_subscriptions IS 'Partner endpoints notified of domain events.';
+COMMENT ON COLUMN webhook_subscriptions.secret IS 'Key of the HMAC-SHA256 signature sent with every delivery; only shown once on creation.';
+COMMENT ON COLUMN webhook_subscriptions.events IS 'Event types delivered to the endpoint, e.g. mission.assigned.';
+COMMENT ON COLUMN webhook_subscriptions.active IS 'Inactive subscriptions receive no new deliveries and their queued ones wait until reactivated.';
+
+COMMENT ON TABLE webhook_deliveries IS 'One event to be sent to one subscription, with its retry state.';
+COMMENT ON COLUMN webhook_deliveries.event_id IS 'The outbox event; unique per subscription so a republished event is not delivered twice.';
+COMMENT ON COLUMN webhook_deliveries.payload IS 'The request body sent to the endpoint.';
+COMMENT ON COLUMN webhook_deliveries.status IS 'pending until delivered, or failed (dead-lettered) once every attempt has failed.';
+COMMENT ON COLUMN webhook_deliveries.next_attempt_at IS 'The delivery is not attempted before this time.';
\ No newline at end of file
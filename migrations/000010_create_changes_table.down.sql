@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS targets_record_change ON targets;
+DROP TRIGGER IF EXISTS missions_record_change ON missions;
+DROP TRIGGER IF EXISTS cats_record_change ON cats;
+DROP TRIGGER IF EXISTS missions_bump_version ON missions;
+DROP FUNCTION IF EXISTS record_change();
+DROP FUNCTION IF EXISTS bump_version();
+DROP TABLE IF EXISTS changes_horizon;
+DROP TABLE IF EXISTS changes;
\ No newline at end of file
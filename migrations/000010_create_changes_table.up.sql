@@ -0,0 +1,70 @@
+CREATE TABLE changes (
+                         id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+
+                         tx_id XID8 NOT NULL DEFAULT pg_current_xact_id(),
+
+                         entity_type VARCHAR(32) NOT NULL,
+                         entity_id INTEGER NOT NULL,
+                         operation VARCHAR(16) NOT NULL CHECK (operation IN ('insert', 'update', 'delete')),
+                         version INTEGER NOT NULL,
+
+                         data JSONB,
+
+                         changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX changes_position_idx ON changes (tx_id, id);
+CREATE INDEX changes_changed_at_idx ON changes (changed_at);
+
+CREATE TABLE changes_horizon (
+                                 singleton BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (singleton),
+
+                                 tx_id XID8 NOT NULL,
+                                 id BIGINT NOT NULL
+);
+
+INSERT INTO changes_horizon (tx_id, id) VALUES ('0', 0);
+
+CREATE FUNCTION record_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO changes (entity_type, entity_id, operation, version)
+        VALUES (TG_ARGV[0], OLD.id, 'delete', OLD.version);
+        RETURN OLD;
+    END IF;
+
+    INSERT INTO changes (entity_type, entity_id, operation, version, data)
+    VALUES (TG_ARGV[0], NEW.id, lower(TG_OP), NEW.version, to_jsonb(NEW));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION bump_version() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER missions_bump_version
+    BEFORE UPDATE ON missions
+    FOR EACH ROW WHEN (NEW.version = OLD.version) EXECUTE FUNCTION bump_version();
+
+CREATE TRIGGER cats_record_change
+    AFTER INSERT OR UPDATE OR DELETE ON cats
+    FOR EACH ROW EXECUTE FUNCTION record_change('cat');
+
+CREATE TRIGGER missions_record_change
+    AFTER INSERT OR UPDATE OR DELETE ON missions
+    FOR EACH ROW EXECUTE FUNCTION record_change('mission');
+
+CREATE TRIGGER targets_record_change
+    AFTER INSERT OR UPDATE OR DELETE ON targets
+    FOR EACH ROW EXECUTE FUNCTION record_change('target');
+
+COMMENT ON TABLE changes IS 'Every insert, update and delete of cats, missions and targets, read by the change feed.';
+COMMENT ON COLUMN changes.tx_id IS 'The writing transaction; the feed is ordered by (tx_id, id) and only serves transactions older than every running one, so no change is skipped.';
+COMMENT ON COLUMN changes.version IS 'The version of the row after the change, or of the deleted row.';
+COMMENT ON COLUMN changes.data IS 'The row after the change. NULL for deletes (tombstones).';
+COMMENT ON TABLE changes_horizon IS 'Position of the newest change removed by the retention cleanup; older cursors have to resync.';
+COMMENT ON TRIGGER missions_bump_version ON missions IS 'Bumps the version on updates that leave it unchanged, like the ON DELETE SET NULL of a deleted cat, so the change feed can order them.';
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX outbox_pending_idx ON outbox (aggregate_type, aggregate_id, id) WHERE published_at IS NULL;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS failed_at;
\ No newline at end of file
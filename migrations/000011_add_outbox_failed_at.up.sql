@@ -0,0 +1,6 @@
+ALTER TABLE outbox ADD COLUMN failed_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX outbox_pending_idx ON outbox (aggregate_type, aggregate_id, id) WHERE published_at IS NULL AND failed_at IS NULL;
+
+COMMENT ON COLUMN outbox.failed_at IS 'When the event was dead-lettered after outbox.max_attempts failed publishes. It is not retried and no longer holds back later events of its aggregate.';
\ No newline at end of file
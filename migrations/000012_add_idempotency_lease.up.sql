@@ -0,0 +1,3 @@
+ALTER TABLE idempotency_keys ADD COLUMN locked_until TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+COMMENT ON COLUMN idempotency_keys.locked_until IS 'Lease of the request processing the key. Once it has passed without a stored response, a retry of the same request may take the key over.';
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE OR REPLACE FUNCTION record_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO changes (entity_type, entity_id, operation, version)
+        VALUES (TG_ARGV[0], OLD.id, 'delete', OLD.version);
+        RETURN OLD;
+    END IF;
+
+    INSERT INTO changes (entity_type, entity_id, operation, version, data)
+    VALUES (TG_ARGV[0], NEW.id, lower(TG_OP), NEW.version, to_jsonb(NEW));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON COLUMN changes.data IS 'The row after the change. NULL for deletes (tombstones).';
\ No newline at end of file
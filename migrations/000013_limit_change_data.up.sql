@@ -0,0 +1,49 @@
+CREATE OR REPLACE FUNCTION record_change() RETURNS TRIGGER AS $$
+DECLARE
+    entity JSONB;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO changes (entity_type, entity_id, operation, version)
+        VALUES (TG_ARGV[0], OLD.id, 'delete', OLD.version);
+        RETURN OLD;
+    END IF;
+
+    IF TG_ARGV[0] = 'cat' THEN
+        entity := jsonb_build_object(
+            'id', NEW.id,
+            'name', NEW.name,
+            'years_of_experience', NEW.years_of_experience,
+            'breed', NEW.breed,
+            'salary', NEW.salary,
+            'created_at', NEW.created_at,
+            'version', NEW.version
+        );
+    ELSIF TG_ARGV[0] = 'mission' THEN
+        entity := jsonb_build_object(
+            'id', NEW.id,
+            'cat_id', NEW.cat_id,
+            'complete', NEW.complete,
+            'created_at', NEW.created_at,
+            'version', NEW.version
+        );
+    ELSIF TG_ARGV[0] = 'target' THEN
+        entity := jsonb_build_object(
+            'id', NEW.id,
+            'mission_id', NEW.mission_id,
+            'name', NEW.name,
+            'country', NEW.country,
+            'notes', NEW.notes,
+            'complete', NEW.complete,
+            'version', NEW.version
+        );
+    ELSE
+        RAISE EXCEPTION 'record_change: unknown entity type %', TG_ARGV[0];
+    END IF;
+
+    INSERT INTO changes (entity_type, entity_id, operation, version, data)
+    VALUES (TG_ARGV[0], NEW.id, lower(TG_OP), NEW.version, entity);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON COLUMN changes.data IS 'The fields of the entity the API exposes, after the change. New table columns only appear once record_change() lists them. NULL for deletes (tombstones).';
\ No newline at end of file